@@ -6,14 +6,17 @@ import (
 )
 
 const (
-	Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	Alphabet      = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	DefaultPrefix = "N"
 )
 
 var (
 	NSG_mainnetAddressP2PKH         = addressEncoder.AddressType{EncodeType: "base58", Alphabet: Alphabet, ChecksumType: "doubleSHA256", HashType: "ripemd160", HashLen: 20, Prefix: nil, Suffix: nil}
-	NSG_testnetAddressP2PKH         = addressEncoder.AddressType{EncodeType: "base58", Alphabet: Alphabet, ChecksumType: "doubleSHA256", HashType: "ripemd160", HashLen: 20, Prefix: nil, Suffix: nil}
 	NSG_mainnetPrivateWIFCompressed = addressEncoder.AddressType{EncodeType: "base58", Alphabet: Alphabet, ChecksumType: "doubleSHA256", HashType: "", HashLen: 32, Prefix: []byte{}, Suffix: nil}
-	NSG_testnetPrivateWIFCompressed = addressEncoder.AddressType{EncodeType: "base58", Alphabet: Alphabet, ChecksumType: "doubleSHA256", HashType: "", HashLen: 32, Prefix: []byte{}, Suffix: nil}
+
+	//测试网与主网的编码相同，网络以地址前缀区分，见AddressDecoderV2.Prefix
+	NSG_testnetAddressP2PKH         = NSG_mainnetAddressP2PKH
+	NSG_testnetPrivateWIFCompressed = NSG_mainnetPrivateWIFCompressed
 
 	Default = AddressDecoderV2{}
 )
@@ -21,6 +24,15 @@ var (
 //AddressDecoderV2
 type AddressDecoderV2 struct {
 	IsTestNet bool
+	Prefix    string //地址前缀，为空时使用DefaultPrefix
+}
+
+//NewAddressDecoderV2 创建指定网络的地址编码器
+func NewAddressDecoderV2(prefix string, isTestNet bool) *AddressDecoderV2 {
+	return &AddressDecoderV2{
+		IsTestNet: isTestNet,
+		Prefix:    prefix,
+	}
 }
 
 //AddressEncode 地址编码
@@ -41,5 +53,9 @@ func (dec *AddressDecoderV2) AddressEncode(hash []byte, opts ...interface{}) (st
 
 	data := owcrypt.Hash(hash, 0, owcrypt.HASH_ALG_SHA256)
 	address := addressEncoder.AddressEncode(data, cfg)
-	return "N" + address, nil
+	prefix := dec.Prefix
+	if len(prefix) == 0 {
+		prefix = DefaultPrefix
+	}
+	return prefix + address, nil
 }
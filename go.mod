@@ -8,7 +8,6 @@ require (
 	github.com/blocktree/go-owcrypt v1.1.1
 	github.com/blocktree/openwallet/v2 v2.0.6
 	github.com/go-errors/errors v1.0.1
-	github.com/imroc/req v0.2.4
 	github.com/shopspring/decimal v0.0.0-20200105231215-408a2507e114
 	github.com/tidwall/gjson v1.3.5
 	gopkg.in/resty.v1 v1.12.0
@@ -32,6 +32,11 @@ func NewAddressDecoder(wm *WalletManager) *AddressDecoder {
 	return &decoder
 }
 
+//encoder 当前钱包网络的地址编码器，不修改共享的addrdec.Default
+func (decoder *AddressDecoder) encoder() *addrdec.AddressDecoderV2 {
+	return addrdec.NewAddressDecoderV2(decoder.wm.Config.Network.AddressPrefix, decoder.wm.Config.IsTestNet)
+}
+
 //PrivateKeyToWIF 私钥转WIF
 func (decoder *AddressDecoder) PrivateKeyToWIF(priv []byte, isTestnet bool) (string, error) {
 
@@ -40,7 +45,7 @@ func (decoder *AddressDecoder) PrivateKeyToWIF(priv []byte, isTestnet bool) (str
 		cfg = addrdec.NSG_testnetPrivateWIFCompressed
 	}
 
-	wif, _ := addrdec.Default.AddressEncode(priv, cfg)
+	wif, _ := decoder.encoder().AddressEncode(priv, cfg)
 
 	return wif, nil
 
@@ -48,8 +53,7 @@ func (decoder *AddressDecoder) PrivateKeyToWIF(priv []byte, isTestnet bool) (str
 
 //PublicKeyToAddress 公钥转地址
 func (decoder *AddressDecoder) PublicKeyToAddress(pub []byte, isTestnet bool) (string, error) {
-	addrdec.Default.IsTestNet = decoder.wm.Config.IsTestNet
-	address, err := addrdec.Default.AddressEncode(pub)
+	address, err := decoder.encoder().AddressEncode(pub)
 	if err != nil {
 		return "", err
 	}
@@ -17,6 +17,8 @@ package nasgo
 
 import (
 	"encoding/hex"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/blocktree/nasgo-adapter/addrdec"
@@ -31,6 +33,36 @@ func TestAddressDecoder_AddressEncode(t *testing.T) {
 
 }
 
+func TestAddressDecoder_PublicKeyToAddress_Networks(t *testing.T) {
+	pub, _ := hex.DecodeString("d67925c8c7fda675b4bf8e3230d2fccafd9c32be6414059bc3aa4bbb87d88548")
+
+	mainnet := NewWalletManager()
+	testnet := NewWalletManager()
+	testnet.Config.IsTestNet = true
+	testnet.Config.SetNetworkParams(TestNetParams)
+	testnet.Config.Network.AddressPrefix = "T"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			addr, _ := mainnet.Decoder.PublicKeyToAddress(pub, false)
+			if !strings.HasPrefix(addr, "N") {
+				t.Errorf("mainnet address = %s, want prefix N", addr)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			addr, _ := testnet.Decoder.PublicKeyToAddress(pub, true)
+			if !strings.HasPrefix(addr, "T") {
+				t.Errorf("testnet address = %s, want prefix T", addr)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 // func TestAddressDecoder_AddressDecode(t *testing.T) {
 
 // 	addrdec.Default.IsTestNet = false
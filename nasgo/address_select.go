@@ -0,0 +1,204 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/blocktree/nasgo-adapter/utils"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	addressPageSize = 1000 //分页查询账户地址的每页数量
+)
+
+//发送地址选择策略
+const (
+	AddressSelectFirst      = "first"      //按地址列表顺序，第一个足够支付的地址
+	AddressSelectLargest    = "largest"    //余额最大的地址优先
+	AddressSelectSmallest   = "smallest"   //足够支付的地址中余额最小的
+	AddressSelectRoundRobin = "roundrobin" //从上次选中地址的下一个开始轮询
+)
+
+//addressBalance 候选发送地址及其转账币种余额
+type addressBalance struct {
+	address *openwallet.Address
+	balance decimal.Decimal //按精度换算的余额
+	fetched bool
+}
+
+//checkAddressSelectPolicy 检查选择策略是否有效
+func checkAddressSelectPolicy(policy string) error {
+	switch policy {
+	case AddressSelectFirst, AddressSelectLargest, AddressSelectSmallest, AddressSelectRoundRobin:
+		return nil
+	}
+	return fmt.Errorf("invalid address select policy: %s", policy)
+}
+
+//getAccountAddresses 分页查询账户的全部地址
+func (decoder *TransactionDecoder) getAccountAddresses(wrapper openwallet.WalletDAI, accountID string) ([]*openwallet.Address, error) {
+
+	addresses := make([]*openwallet.Address, 0)
+	for offset := 0; ; offset += addressPageSize {
+		page, err := wrapper.GetAddressList(offset, addressPageSize, "AccountID", accountID)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, page...)
+		if len(page) < addressPageSize {
+			break
+		}
+	}
+
+	if len(addresses) == 0 {
+		return nil, openwallet.Errorf(openwallet.ErrAccountNotAddress, "[%s] have not address", accountID)
+	}
+
+	return addresses, nil
+}
+
+//fetchAddressBalance 查询地址的转账币种余额
+func (decoder *TransactionDecoder) fetchAddressBalance(candidate *addressBalance, coin openwallet.Coin) error {
+
+	if candidate.fetched {
+		return nil
+	}
+
+	if coin.IsContract {
+		b, err := decoder.wm.WalletClient.Wallet.GetAssetsBalance(candidate.address.Address, coin.Contract.Address)
+		if err != nil {
+			return err
+		}
+		balance, err := b.RawBalance()
+		if err != nil {
+			return err
+		}
+		candidate.balance, err = utils.FromRawAmount(balance, int32(coin.Contract.Decimals))
+		if err != nil {
+			return err
+		}
+	} else {
+		b, err := decoder.wm.WalletClient.Wallet.GetBalance(candidate.address.Address)
+		if err != nil {
+			return err
+		}
+		candidate.balance = utils.RawToDecimal(b, decoder.wm.Decimal())
+	}
+	candidate.fetched = true
+
+	return nil
+}
+
+//collectCandidateBalances 并发查询候选地址的转账币种余额，查询失败的地址记录日志后跳过
+func (decoder *TransactionDecoder) collectCandidateBalances(candidates []*addressBalance, coin openwallet.Coin) []*addressBalance {
+
+	precision := decoder.wm.Decimal()
+	if coin.IsContract {
+		precision = int32(coin.Contract.Decimals)
+	}
+
+	addresses := make([]*openwallet.Address, 0, len(candidates))
+	for _, candidate := range candidates {
+		addresses = append(addresses, candidate.address)
+	}
+
+	result := make([]*addressBalance, 0, len(candidates))
+	for i, b := range decoder.collectSummaryBalances(addresses, coin) {
+		err := b.err
+		if err == nil {
+			candidates[i].balance, err = utils.FromRawAmount(b.balance, precision)
+		}
+		if err != nil {
+			decoder.wm.Log.Notice("get balance of address [%s] failed, skip it: %v", b.address.Address, err)
+			continue
+		}
+		candidates[i].fetched = true
+		result = append(result, candidates[i])
+	}
+
+	return result
+}
+
+//orderAddressesByPolicy 按选择策略排列候选发送地址
+//扩展参数from指定发送地址时只返回该地址，扩展参数addressPolicy可覆盖配置的策略
+func (decoder *TransactionDecoder) orderAddressesByPolicy(rawTx *openwallet.RawTransaction, addresses []*openwallet.Address) ([]*addressBalance, error) {
+
+	ext := rawTx.GetExtParam()
+
+	if from := ext.Get("from").String(); len(from) > 0 {
+		for _, addr := range addresses {
+			if addr.Address == from {
+				return []*addressBalance{{address: addr}}, nil
+			}
+		}
+		return nil, openwallet.Errorf(openwallet.ErrAddressNotFound, "address [%s] is not belong to account [%s]", from, rawTx.Account.AccountID)
+	}
+
+	policy := decoder.wm.Config.AddressSelectPolicy
+	if value := ext.Get("addressPolicy").String(); len(value) > 0 {
+		policy = value
+	}
+	if err := checkAddressSelectPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*addressBalance, 0, len(addresses))
+	start := 0
+	if policy == AddressSelectRoundRobin {
+		start = decoder.roundRobinCursor(rawTx.Account.AccountID) % len(addresses)
+	}
+	for i := range addresses {
+		candidates = append(candidates, &addressBalance{address: addresses[(start+i)%len(addresses)]})
+	}
+
+	if policy == AddressSelectLargest || policy == AddressSelectSmallest {
+		candidates = decoder.collectCandidateBalances(candidates, rawTx.Coin)
+		if len(candidates) == 0 {
+			return nil, openwallet.Errorf(openwallet.ErrCallFullNodeAPIFailed, "get balance of addresses of account [%s] failed", rawTx.Account.AccountID)
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if policy == AddressSelectLargest {
+				return candidates[i].balance.GreaterThan(candidates[j].balance)
+			}
+			return candidates[i].balance.LessThan(candidates[j].balance)
+		})
+	}
+
+	return candidates, nil
+}
+
+//roundRobinCursor 账户轮询的起始位置
+func (decoder *TransactionDecoder) roundRobinCursor(accountID string) int {
+	decoder.cursorMu.Lock()
+	defer decoder.cursorMu.Unlock()
+	return decoder.roundRobin[accountID]
+}
+
+//markSelectedAddress 记录选中的地址，轮询策略下次从其下一个地址开始
+func (decoder *TransactionDecoder) markSelectedAddress(accountID string, addresses []*openwallet.Address, selected *openwallet.Address) {
+	decoder.cursorMu.Lock()
+	defer decoder.cursorMu.Unlock()
+	for i, addr := range addresses {
+		if addr.Address == selected.Address {
+			decoder.roundRobin[accountID] = i + 1
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+func TestTransactionDecoder_OrderAddressesByPolicy(t *testing.T) {
+	wm := NewWalletManager()
+	decoder := wm.TxDecoder.(*TransactionDecoder)
+	addresses := []*openwallet.Address{{Address: "A"}, {Address: "B"}, {Address: "C"}}
+	rawTx := &openwallet.RawTransaction{Account: &openwallet.AssetsAccount{AccountID: "account"}}
+
+	order := func() string {
+		candidates, err := decoder.orderAddressesByPolicy(rawTx, addresses)
+		if err != nil {
+			t.Fatalf("orderAddressesByPolicy() error = %v", err)
+		}
+		result := ""
+		for _, candidate := range candidates {
+			result += candidate.address.Address
+		}
+		return result
+	}
+
+	if got := order(); got != "ABC" {
+		t.Errorf("first policy order = %s, want ABC", got)
+	}
+
+	wm.Config.AddressSelectPolicy = AddressSelectRoundRobin
+	decoder.markSelectedAddress("account", addresses, addresses[1])
+	if got := order(); got != "CAB" {
+		t.Errorf("roundrobin policy order = %s, want CAB", got)
+	}
+	decoder.markSelectedAddress("account", addresses, addresses[2])
+	if got := order(); got != "ABC" {
+		t.Errorf("roundrobin policy order = %s, want ABC", got)
+	}
+
+	rawTx.SetExtParam("from", "B")
+	if got := order(); got != "B" {
+		t.Errorf("fixed from order = %s, want B", got)
+	}
+
+	rawTx.SetExtParam("from", "D")
+	if _, err := decoder.orderAddressesByPolicy(rawTx, addresses); err == nil {
+		t.Errorf("orderAddressesByPolicy() want error for address not in account")
+	}
+
+	rawTx.ExtParam = ""
+	rawTx.SetExtParam("addressPolicy", "unknown")
+	if _, err := decoder.orderAddressesByPolicy(rawTx, addresses); err == nil {
+		t.Errorf("orderAddressesByPolicy() want error for invalid policy")
+	}
+}
+
+func TestTransactionDecoder_OrderAddressesByPolicy_Balance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/accounts/getBalance" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.URL.Query().Get("address") {
+		case "A":
+			fmt.Fprint(w, `{"success":true,"balance":200}`)
+		case "B":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "C":
+			fmt.Fprint(w, `{"success":true,"balance":500}`)
+		case "D":
+			fmt.Fprint(w, `{"success":true,"balance":100}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = rpc.NewClient(server.URL)
+	decoder := wm.TxDecoder.(*TransactionDecoder)
+	addresses := []*openwallet.Address{{Address: "A"}, {Address: "B"}, {Address: "C"}, {Address: "D"}}
+	rawTx := &openwallet.RawTransaction{Account: &openwallet.AssetsAccount{AccountID: "account"}, Coin: openwallet.Coin{Symbol: "NSG"}}
+
+	order := func(policy string) string {
+		rawTx.ExtParam = ""
+		rawTx.SetExtParam("addressPolicy", policy)
+		candidates, err := decoder.orderAddressesByPolicy(rawTx, addresses)
+		if err != nil {
+			t.Fatalf("orderAddressesByPolicy(%s) error = %v", policy, err)
+		}
+		result := ""
+		for _, candidate := range candidates {
+			if !candidate.fetched {
+				t.Errorf("candidate %s balance is not fetched", candidate.address.Address)
+			}
+			result += candidate.address.Address
+		}
+		return result
+	}
+
+	//B查询失败被跳过
+	if got := order(AddressSelectLargest); got != "CAD" {
+		t.Errorf("largest policy order = %s, want CAD", got)
+	}
+	if got := order(AddressSelectSmallest); got != "DAC" {
+		t.Errorf("smallest policy order = %s, want DAC", got)
+	}
+
+	//全部查询失败时返回错误
+	rawTx.ExtParam = ""
+	rawTx.SetExtParam("addressPolicy", AddressSelectLargest)
+	if _, err := decoder.orderAddressesByPolicy(rawTx, []*openwallet.Address{{Address: "B"}, {Address: "E"}}); err == nil {
+		t.Errorf("orderAddressesByPolicy() want error when all balances failed")
+	}
+}
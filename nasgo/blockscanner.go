@@ -18,12 +18,13 @@ package nasgo
 import (
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/nasgo-adapter/utils"
 	"github.com/blocktree/openwallet/v2/log"
 	"github.com/blocktree/openwallet/v2/openwallet"
-	"github.com/shopspring/decimal"
 )
 
 const (
@@ -36,21 +37,34 @@ const (
 type BlockScanner struct {
 	*openwallet.BlockScannerBase
 
-	CurrentBlockHeight   uint64         //当前区块高度
-	extractingCH         chan struct{}  //扫描工作令牌
-	wm                   *WalletManager //钱包管理者
-	IsScanMemPool        bool           //是否扫描交易池
-	RescanLastBlockCount uint64         //重扫上N个区块数量
+	CurrentBlockHeight   uint64          //当前区块高度
+	extractingCH         chan struct{}   //扫描工作令牌
+	wm                   *WalletManager  //钱包管理者
+	IsScanMemPool        bool            //是否扫描交易池
+	RescanLastBlockCount uint64          //重扫上N个区块数量
+	TokenFilterFunc      TokenFilterFunc //判断UIA资产是否已登记，优先于配置的资产列表
+	NodeHealth           *NodeHealth     //最近一次扫块前的节点健康状态
+	ConfirmTracker       *ConfirmTracker //提取交易单的确认数追踪
 }
 
 //ExtractResult extract result
 type ExtractResult struct {
 	extractData map[string][]*openwallet.TxExtractData
-	TxID        string
-	BlockHash   string
-	BlockHeight uint64
-	BlockTime   int64
-	Success     bool
+	//合约回执，key为contractID
+	extractContractData map[string][]*openwallet.SmartContractReceipt
+	//资产未登记
+	tokenUnregistered bool
+	//订阅地址收发的未登记资产
+	unregisteredTokens []*UnregisteredToken
+	//充值的备注标签
+	depositTag string
+	//备注标签未匹配充值账户
+	depositUnmatched bool
+	TxID             string
+	BlockHash        string
+	BlockHeight      uint64
+	BlockTime        int64
+	Success          bool
 }
 
 //SaveResult result
@@ -70,6 +84,7 @@ func NewBlockScanner(wm *WalletManager) *BlockScanner {
 	bs.wm = wm
 	bs.IsScanMemPool = false
 	bs.RescanLastBlockCount = 0
+	bs.ConfirmTracker = NewConfirmTracker(wm)
 
 	// set task
 	bs.SetTask(bs.ScanBlockTask)
@@ -85,6 +100,14 @@ func (bs *BlockScanner) ScanBlockTask() {
 		currentHash   string
 	)
 
+	//节点不可用时暂停本轮扫块
+	health := bs.wm.Health()
+	bs.NodeHealth = health
+	if !health.Healthy() {
+		bs.wm.Log.Std.Warning("block scanner paused, %s", health.String())
+		return
+	}
+
 	// get local block header
 	currentHeight, currentHash, err := bs.GetLocalBlockHead()
 
@@ -96,8 +119,9 @@ func (bs *BlockScanner) ScanBlockTask() {
 		bs.wm.Log.Std.Info("No records found in local, get current block as the local!")
 
 		headBlock, err := bs.GetGlobalHeadBlock()
-		if err != nil {
+		if err != nil || headBlock == nil {
 			bs.wm.Log.Std.Info("get head block error, err=%v", err)
+			return
 		}
 
 		currentHash = headBlock.Header.PrevBlock
@@ -111,13 +135,27 @@ func (bs *BlockScanner) ScanBlockTask() {
 		}
 
 		maxBlockHeight := bs.GetGlobalMaxBlockHeight()
+		if maxBlockHeight == 0 {
+			bs.wm.Log.Std.Warning("block scanner paused, can not get max block height from node")
+			break
+		}
 
 		bs.wm.Log.Info("current block height:", currentHeight, " maxBlockHeight:", maxBlockHeight)
-		if uint64(currentHeight) == maxBlockHeight-1 {
+		if uint64(currentHeight) >= maxBlockHeight-1 {
 			bs.wm.Log.Std.Info("block scanner has scanned full chain data. Current height %d", maxBlockHeight)
 			break
 		}
 
+		//落后较多时进入追块模式，并行预取区块，按顺序提交
+		if window := uint64(bs.wm.Config.CatchUpWindow); window > 1 && maxBlockHeight-1-uint64(currentHeight) > window {
+			height, hash := bs.catchUpBlocks(currentHeight, currentHash, int(window))
+			if height > currentHeight {
+				currentHeight, currentHash = height, hash
+				continue
+			}
+			//预取失败或分叉，回到单块模式处理
+		}
+
 		// next block
 		currentHeight = currentHeight + 1
 
@@ -162,6 +200,7 @@ func (bs *BlockScanner) ScanBlockTask() {
 
 			//重新记录一个新扫描起点
 			bs.SaveLocalBlockHead(currentHeight, currentHash)
+			bs.ConfirmTracker.Rollback(uint64(currentHeight))
 
 			if forkBlock != nil {
 				//通知分叉区块给观测者，异步处理
@@ -180,6 +219,8 @@ func (bs *BlockScanner) ScanBlockTask() {
 			bs.SaveLocalBlock(block)
 			//通知新区块给观测者，异步处理
 			bs.newBlockNotify(block)
+			//通知到达确认数阈值的交易单
+			bs.ConfirmTracker.Update(uint64(currentHeight))
 		}
 	}
 
@@ -188,6 +229,84 @@ func (bs *BlockScanner) ScanBlockTask() {
 
 }
 
+//prefetchedBlock 预取的区块及交易单
+type prefetchedBlock struct {
+	block        *Block
+	transactions []*rpc.Transaction
+	err          error
+}
+
+//prefetchBlocks 并行获取从height开始的count个区块及其交易单
+func (bs *BlockScanner) prefetchBlocks(height uint32, count int) []*prefetchedBlock {
+
+	results := make([]*prefetchedBlock, count)
+
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			result := &prefetchedBlock{}
+			results[index] = result
+
+			block, err := bs.GetByHeight(height + uint32(index))
+			if err != nil {
+				result.err = err
+				return
+			}
+			result.block = block
+			result.transactions, result.err = bs.GetBlockTransactions(block.ID)
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+//catchUpBlocks 追块模式，预取currentHeight之后window个区块，校验前块hash后按顺序提交
+//返回已提交的最后高度和hash，遇到错误或分叉时停止
+func (bs *BlockScanner) catchUpBlocks(currentHeight uint32, currentHash string, window int) (uint32, string) {
+
+	bs.wm.Log.Std.Info("block scanner catching up heights: %d - %d ...", currentHeight+1, currentHeight+uint32(window))
+
+	prefetched := bs.prefetchBlocks(currentHeight+1, window)
+
+	for _, p := range prefetched {
+		if !bs.Scanning {
+			break
+		}
+
+		if p.err != nil {
+			bs.wm.Log.Std.Info("block scanner prefetch height: %d failed; unexpected error: %v", currentHeight+1, p.err)
+			break
+		}
+
+		block := p.block
+		if block.PrevBlock != currentHash {
+			bs.wm.Log.Std.Info("block scanner prefetch height: %d previous hash mismatch", block.Height)
+			break
+		}
+
+		bs.wm.Log.Std.Info("block scanner scanning height: %d ...", block.Height)
+		_, err := bs.extractTransactions(block.Height, block.ID, block.Timestamp, p.transactions)
+		if err != nil {
+			bs.wm.Log.Std.Error("block scanner ran extractTransactions occured unexpected error: %v", err)
+		}
+
+		currentHeight = uint32(block.Height)
+		currentHash = block.ID
+
+		//保存本地新高度
+		bs.SaveLocalBlockHead(currentHeight, currentHash)
+		bs.SaveLocalBlock(block)
+		//通知新区块给观测者，异步处理
+		bs.newBlockNotify(block)
+		bs.ConfirmTracker.Update(uint64(currentHeight))
+	}
+
+	return currentHeight, currentHash
+}
+
 //newBlockNotify 获得新区块后，通知给观测者
 func (bs *BlockScanner) forkBlockNotify(block *Block) {
 	header := block.BlockHeader(bs.wm.Symbol())
@@ -204,20 +323,31 @@ func (bs *BlockScanner) newBlockNotify(block *Block) {
 // BatchExtractTransactions 批量提取交易单
 func (bs *BlockScanner) BatchExtractTransactions(blockHeight uint64, blockHash string, blockTime int64) error {
 
-	var (
-		quit       = make(chan struct{})
-		done       = 0 //完成标记
-		failed     = 0
-		shouldDone = 0 //需要完成的总数
-	)
-
-	transactions, err := bs.wm.WalletClient.Tx.GetTransactionsByBlock(blockHash)
+	transactions, err := bs.GetBlockTransactions(blockHash)
 	if err != nil {
 		bs.wm.Log.Std.Info("block scanner can not get transactions; unexpected error: %v", err)
+		//记录未扫区块
+		unscanRecord := openwallet.NewUnscanRecord(blockHeight, "", err.Error(), bs.wm.Symbol())
+		bs.SaveUnscanRecord(unscanRecord)
+		return err
 	}
 
+	_, err = bs.extractTransactions(blockHeight, blockHash, blockTime, transactions)
+	return err
+}
+
+//extractTransactions 并发提取区块的交易单，失败的交易单按txid记录未扫记录，返回失败的txid
+func (bs *BlockScanner) extractTransactions(blockHeight uint64, blockHash string, blockTime int64, transactions []*rpc.Transaction) ([]string, error) {
+
+	var (
+		quit        = make(chan struct{})
+		done        = 0 //完成标记
+		failedTxIDs = make([]string, 0)
+		shouldDone  = 0 //需要完成的总数
+	)
+
 	if len(transactions) == 0 {
-		return nil
+		return failedTxIDs, nil
 	}
 
 	shouldDone = len(transactions)
@@ -236,17 +366,31 @@ func (bs *BlockScanner) BatchExtractTransactions(blockHeight uint64, blockHash s
 		//回收创建的地址
 		for gets := range result {
 
+			reason := ""
 			if gets.Success {
 				notifyErr := bs.newExtractDataNotify(height, gets.extractData)
 				if notifyErr != nil {
-					failed++ //标记保存失败数
+					reason = "ExtractData Notify failed."
 					bs.wm.Log.Std.Info("newExtractDataNotify unexpected error: %v", notifyErr)
 				}
+				notifyErr = bs.newExtractContractDataNotify(height, gets.extractContractData)
+				if notifyErr != nil {
+					reason = "ExtractContractData Notify failed."
+					bs.wm.Log.Std.Info("newExtractContractDataNotify unexpected error: %v", notifyErr)
+				}
+				bs.newUnregisteredTokenNotify(gets.unregisteredTokens)
 			} else {
-				//记录未扫区块
-				unscanRecord := openwallet.NewUnscanRecord(height, "", "", bs.wm.Symbol())
-				bs.SaveUnscanRecord(unscanRecord)
-				failed++ //标记保存失败数
+				reason = "extract transaction failed."
+			}
+
+			if len(reason) > 0 {
+				//记录未扫交易单，重扫时只处理该交易单
+				unscanRecord := openwallet.NewUnscanRecord(height, gets.TxID, reason, bs.wm.Symbol())
+				err := bs.SaveUnscanRecord(unscanRecord)
+				if err != nil {
+					bs.wm.Log.Std.Error("block height: %d, txid: %s, save unscan record failed. unexpected error: %v", height, gets.TxID, err)
+				}
+				failedTxIDs = append(failedTxIDs, gets.TxID) //标记保存失败的交易单
 			}
 			//累计完成的线程数
 			done++
@@ -281,11 +425,55 @@ func (bs *BlockScanner) BatchExtractTransactions(blockHeight uint64, blockHash s
 	//以下使用生产消费模式
 	bs.extractRuntime(producer, worker, quit)
 
-	if failed > 0 {
-		return fmt.Errorf("block scanner saveWork failed")
+	if len(failedTxIDs) > 0 {
+		return failedTxIDs, fmt.Errorf("block scanner saveWork failed, transactions: %d", len(failedTxIDs))
 	}
 
-	return nil
+	return failedTxIDs, nil
+}
+
+//GetBlockTransactions 获取区块的交易单，资产交易使用UIA接口的数据替换
+func (bs *BlockScanner) GetBlockTransactions(blockHash string) ([]*rpc.Transaction, error) {
+
+	transactions, err := bs.wm.WalletClient.Tx.GetTransactionsByBlock(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	hasAsset := false
+	for _, trx := range transactions {
+		if trx.Type == rpc.TxType_Asset {
+			hasAsset = true
+			break
+		}
+	}
+
+	if !hasAsset {
+		return transactions, nil
+	}
+
+	assetTxs, err := bs.wm.WalletClient.Tx.GetAssetTransactionsByBlock(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	assetMap := make(map[string]*rpc.Transaction)
+	for _, trx := range assetTxs {
+		assetMap[trx.ID] = trx
+	}
+
+	for i, trx := range transactions {
+		if trx.Type != rpc.TxType_Asset {
+			continue
+		}
+		assetTx, ok := assetMap[trx.ID]
+		if !ok || assetTx.Asset == nil || assetTx.Asset.UiaTransfer == nil {
+			return nil, fmt.Errorf("asset transaction [%s] detail is missing in block [%s]", trx.ID, blockHash)
+		}
+		transactions[i] = assetTx
+	}
+
+	return transactions, nil
 }
 
 //extractRuntime 提取运行时
@@ -319,42 +507,67 @@ func (bs *BlockScanner) extractRuntime(producer chan ExtractResult, worker chan
 
 // ExtractTransaction 提取交易单
 func (bs *BlockScanner) ExtractTransaction(blockHeight uint64, blockHash string, blockTime int64, trx *rpc.Transaction, scanTargetFunc openwallet.BlockScanTargetFunc) ExtractResult {
+	return bs.extractTransaction(blockHeight, blockHash, blockTime, trx, scanTargetFunc, bs.ScanTargetFuncV2)
+}
+
+//extractTransaction 提取交易单，scanTargetFuncV2用于查找订阅的合约
+func (bs *BlockScanner) extractTransaction(blockHeight uint64, blockHash string, blockTime int64, trx *rpc.Transaction, scanTargetFunc openwallet.BlockScanTargetFunc, scanTargetFuncV2 openwallet.BlockScanTargetFuncV2) ExtractResult {
 	var (
 		success = true
 		result  = ExtractResult{
-			BlockHash:   blockHash,
-			BlockHeight: blockHeight,
-			TxID:        trx.ID,
-			extractData: make(map[string][]*openwallet.TxExtractData),
-			BlockTime:   time.Now().Unix(),
+			BlockHash:           blockHash,
+			BlockHeight:         blockHeight,
+			TxID:                trx.ID,
+			extractData:         make(map[string][]*openwallet.TxExtractData),
+			extractContractData: make(map[string][]*openwallet.SmartContractReceipt),
+			BlockTime:           time.Now().Unix(),
 		}
-		err error
 	)
 
 	if trx.Type == rpc.TxType_Asset {
-		txid := trx.ID
-		trx, err = bs.wm.WalletClient.Tx.GetTransaction(txid)
-		if err != nil {
-			bs.wm.Log.Std.Debug("get asset transaction fail: [%v] ", txid)
-			return ExtractResult{Success: true}
+		if trx.Asset == nil || trx.Asset.UiaTransfer == nil {
+			bs.wm.Log.Std.Error("transaction asset info missing: [%v] ", trx.ID)
+			return ExtractResult{TxID: trx.ID, BlockHeight: blockHeight, Success: false}
+		}
+		if _, err := trx.Asset.UiaTransfer.RawAmount(); err != nil {
+			bs.wm.Log.Std.Error("transaction [%v] uia amount: %v", trx.ID, err)
+			return ExtractResult{TxID: trx.ID, BlockHeight: blockHeight, Success: false}
 		}
-	} else if trx.Type != 0 {
-		bs.wm.Log.Std.Debug("does not support transaction type: [%v] ", trx.Type)
-		return ExtractResult{Success: true}
+		result.tokenUnregistered = !bs.IsTokenRegistered(trx.Asset.UiaTransfer.Currency, scanTargetFuncV2)
 	}
 
 	if scanTargetFunc == nil {
 		bs.wm.Log.Std.Error("scanTargetFunc is not configurated")
-		return ExtractResult{Success: false}
+		return ExtractResult{TxID: trx.ID, BlockHeight: blockHeight, Success: false}
+	}
+
+	//订阅的UIA合约，提取合约回执
+	if err := bs.ExtractSmartContractReceipt(trx, &result, scanTargetFuncV2); err != nil {
+		bs.wm.Log.Std.Error("extract smart contract receipt failed: %v", err)
+		return ExtractResult{TxID: trx.ID, BlockHeight: blockHeight, Success: false}
 	}
 
 	from := trx.SenderID
 	to := trx.RecipientId
 
+	//其他类型的交易单，只提取订阅发送者支付的手续费和金额
+	if trx.Type != rpc.TxType_NSG && trx.Type != rpc.TxType_Asset {
+		accountID, ok := scanTargetFunc(openwallet.ScanTarget{Address: from, Symbol: bs.wm.Symbol(), BalanceModelType: openwallet.BalanceModelTypeAddress})
+		if ok {
+			bs.InitFeeExtractResult(accountID, trx, &result)
+		}
+		result.Success = success
+		return result
+	}
+
 	//订阅地址为交易单中的发送者
 	accountID1, ok1 := scanTargetFunc(openwallet.ScanTarget{Address: from, Symbol: bs.wm.Symbol(), BalanceModelType: openwallet.BalanceModelTypeAddress})
 	//订阅地址为交易单中的接收者
 	accountID2, ok2 := scanTargetFunc(openwallet.ScanTarget{Address: to, Symbol: bs.wm.Symbol(), BalanceModelType: openwallet.BalanceModelTypeAddress})
+	//按备注标签查找充值账户，账户内部转账不处理
+	if bs.wm.Config.DepositTagMode && !(ok1 && accountID1 == accountID2) {
+		accountID2, ok2 = bs.routeDeposit(to, trx.Message, accountID2, ok2, scanTargetFunc, &result)
+	}
 	if accountID1 == accountID2 && len(accountID1) > 0 && len(accountID2) > 0 {
 		bs.InitExtractResult(accountID1, trx, &result, 0)
 	} else {
@@ -384,7 +597,7 @@ func (bs *BlockScanner) InitExtractResult(sourceKey string, trx *rpc.Transaction
 
 	status := "1"
 	reason := ""
-	amount := decimal.New(int64(trx.Amount), -bs.wm.Decimal()).String()
+	amount := utils.RawToDecimal(trx.Amount, bs.wm.Decimal()).String()
 	from := trx.SenderID
 	to := trx.RecipientId
 	coin := openwallet.Coin{
@@ -410,13 +623,13 @@ func (bs *BlockScanner) InitExtractResult(sourceKey string, trx *rpc.Transaction
 
 		if optType == 0 || optType == 1 {
 
-			mainAmount := decimal.New(int64(trx.Amount), -bs.wm.Decimal()).String()
+			mainAmount := utils.RawToDecimal(trx.Amount, bs.wm.Decimal()).String()
 			mainCoin := openwallet.Coin{
 				IsContract: false,
 				Symbol:     bs.wm.Symbol(),
 			}
 
-			fees := decimal.New(int64(trx.Fee), -bs.wm.Decimal()).String()
+			fees := utils.RawToDecimal(trx.Fee, bs.wm.Decimal()).String()
 			feeExtractData := &openwallet.TxExtractData{}
 			feeTransx := &openwallet.Transaction{
 				Coin:        mainCoin,
@@ -454,6 +667,27 @@ func (bs *BlockScanner) InitExtractResult(sourceKey string, trx *rpc.Transaction
 
 			txExtractDataArray = append(txExtractDataArray, feeExtractData)
 		}
+
+		if result.tokenUnregistered {
+			result.unregisteredTokens = append(result.unregisteredTokens, &UnregisteredToken{
+				Currency:    token,
+				SourceKey:   sourceKey,
+				TxID:        result.TxID,
+				BlockHeight: result.BlockHeight,
+				BlockHash:   result.BlockHash,
+				From:        from,
+				To:          to,
+				Amount:      trx.Asset.UiaTransfer.Amount,
+			})
+
+			//不提取未登记资产的转账
+			if bs.wm.Config.TokenFilterAction != TokenFilterActionTag {
+				if len(txExtractDataArray) > 0 {
+					result.extractData[sourceKey] = txExtractDataArray
+				}
+				return
+			}
+		}
 	}
 
 	transx := &openwallet.Transaction{
@@ -472,10 +706,19 @@ func (bs *BlockScanner) InitExtractResult(sourceKey string, trx *rpc.Transaction
 		TxType:      0,
 	}
 	if trx.Type == rpc.TxType_NSG {
-		transx.Fees = decimal.New(int64(trx.Fee), -bs.wm.Decimal()).String()
+		transx.Fees = utils.RawToDecimal(trx.Fee, bs.wm.Decimal()).String()
 	}
 
 	transx.SetExtParam("memo", trx.Message)
+	if result.tokenUnregistered {
+		transx.SetExtParam("unregisteredToken", true)
+	}
+	if optType == 2 && len(result.depositTag) > 0 {
+		transx.SetExtParam("depositTag", result.depositTag)
+	}
+	if optType == 2 && result.depositUnmatched {
+		transx.SetExtParam("depositUnmatched", true)
+	}
 
 	wxID := openwallet.GenTransactionWxID(transx)
 	transx.WxID = wxID
@@ -495,6 +738,140 @@ func (bs *BlockScanner) InitExtractResult(sourceKey string, trx *rpc.Transaction
 	result.extractData[sourceKey] = txExtractDataArray
 }
 
+//InitFeeExtractResult 提取非转账类型交易单，发送者支付的手续费为第一个TxInput，金额大于0时为第二个TxInput
+//交易类型及类型相关的内容记录在扩展参数
+func (bs *BlockScanner) InitFeeExtractResult(sourceKey string, trx *rpc.Transaction, result *ExtractResult) {
+
+	from := trx.SenderID
+	fees := utils.RawToDecimal(trx.Fee, bs.wm.Decimal()).String()
+	amount := utils.RawToDecimal(trx.Amount, bs.wm.Decimal()).String()
+	coin := openwallet.Coin{
+		Symbol:     bs.wm.Symbol(),
+		IsContract: false,
+	}
+
+	transx := &openwallet.Transaction{
+		Coin:        coin,
+		Fees:        fees,
+		BlockHash:   result.BlockHash,
+		BlockHeight: result.BlockHeight,
+		TxID:        result.TxID,
+		Amount:      amount,
+		ConfirmTime: result.BlockTime,
+		From:        []string{from + ":" + amount},
+		To:          []string{},
+		IsMemo:      true,
+		Status:      "1",
+		Reason:      "",
+		TxType:      1,
+	}
+	if len(trx.RecipientId) > 0 {
+		transx.To = []string{trx.RecipientId + ":" + amount}
+	}
+
+	transx.SetExtParam("memo", trx.Message)
+	for key, value := range txTypeExtParams(trx) {
+		transx.SetExtParam(key, value)
+	}
+
+	transx.WxID = openwallet.GenTransactionWxID(transx)
+
+	txExtractData := &openwallet.TxExtractData{Transaction: transx}
+
+	feeCharge := &openwallet.TxInput{}
+	feeCharge.Sid = openwallet.GenTxInputSID(transx.TxID, bs.wm.Symbol(), "", uint64(0))
+	feeCharge.TxID = transx.TxID
+	feeCharge.Address = from
+	feeCharge.Coin = coin
+	feeCharge.Amount = fees
+	feeCharge.Symbol = coin.Symbol
+	feeCharge.BlockHash = transx.BlockHash
+	feeCharge.BlockHeight = transx.BlockHeight
+	feeCharge.Index = 0
+	feeCharge.CreateAt = time.Now().Unix()
+	feeCharge.TxType = transx.TxType
+	txExtractData.TxInputs = append(txExtractData.TxInputs, feeCharge)
+
+	//如充值到DApp，金额也从发送者扣除
+	if trx.Amount > 0 {
+		tmp := *feeCharge
+		amountCharge := &tmp
+		amountCharge.Sid = openwallet.GenTxInputSID(transx.TxID, bs.wm.Symbol(), "", uint64(1))
+		amountCharge.Amount = amount
+		amountCharge.Index = 1
+		amountCharge.TxType = 0
+		txExtractData.TxInputs = append(txExtractData.TxInputs, amountCharge)
+	}
+
+	result.extractData[sourceKey] = append(result.extractData[sourceKey], txExtractData)
+}
+
+//txTypeExtParams 交易类型及类型相关内容的扩展参数
+func txTypeExtParams(trx *rpc.Transaction) map[string]interface{} {
+
+	params := map[string]interface{}{
+		"txType":     trx.Type,
+		"txTypeName": rpc.TxTypeName(trx.Type),
+	}
+
+	asset := trx.Asset
+	if asset == nil {
+		return params
+	}
+
+	switch trx.Type {
+	case rpc.TxType_SetSecureCode:
+		if asset.Signature != nil {
+			params["publicKey"] = asset.Signature.PublicKey
+		}
+	case rpc.TxType_Delegate:
+		if asset.Delegate != nil {
+			params["username"] = asset.Delegate.Username
+		}
+	case rpc.TxType_Vote:
+		if asset.Vote != nil {
+			params["votes"] = asset.Vote.Votes
+		}
+	case rpc.TxType_MultiSig:
+		if asset.Multisignature != nil {
+			params["min"] = asset.Multisignature.Min
+			params["lifetime"] = asset.Multisignature.Lifetime
+			params["keysgroup"] = asset.Multisignature.Keysgroup
+		}
+	case rpc.TxType_PublishDAPP:
+		if asset.Dapp != nil {
+			params["dappName"] = asset.Dapp.Name
+		}
+	case rpc.TxType_DeopsitDAPP, rpc.TxType_WithdrawalDAPP:
+		transfer := asset.InTransfer
+		if trx.Type == rpc.TxType_WithdrawalDAPP {
+			transfer = asset.OutTransfer
+		}
+		if transfer != nil {
+			params["dappId"] = transfer.DappID
+			params["currency"] = transfer.Currency
+			params["currencyAmount"] = transfer.Amount
+		}
+	case rpc.TxType_RegPublisher:
+		if asset.UiaIssuer != nil {
+			params["issuer"] = asset.UiaIssuer.Name
+		}
+	case rpc.TxType_RegAsset:
+		if asset.UiaAsset != nil {
+			params["asset"] = asset.UiaAsset.Name
+			params["maximum"] = asset.UiaAsset.Maximum
+			params["precision"] = asset.UiaAsset.Precision
+		}
+	case rpc.TxType_IssueAsset:
+		if asset.UiaIssue != nil {
+			params["asset"] = asset.UiaIssue.Currency
+			params["currencyAmount"] = asset.UiaIssue.Amount
+		}
+	}
+
+	return params
+}
+
 //extractTxInput 提取交易单输入部分,无需手续费，所以只包含1个TxInput
 func (bs *BlockScanner) extractTxInput(trx *rpc.Transaction, txExtractData *openwallet.TxExtractData) {
 
@@ -520,7 +897,7 @@ func (bs *BlockScanner) extractTxInput(trx *rpc.Transaction, txExtractData *open
 
 	if trx.Type == rpc.TxType_NSG && trx.Fee > 0 {
 		//手续费也作为一个输出s
-		fees := decimal.New(int64(trx.Fee), -bs.wm.Decimal()).String()
+		fees := utils.RawToDecimal(trx.Fee, bs.wm.Decimal()).String()
 		tmp := *txInput
 		feeCharge := &tmp
 		feeCharge.Amount = fees
@@ -554,28 +931,29 @@ func (bs *BlockScanner) extractTxOutput(trx *rpc.Transaction, txExtractData *ope
 	txExtractData.TxOutputs = append(txExtractData.TxOutputs, txOutput)
 }
 
-//newExtractDataNotify 发送通知
+//newExtractDataNotify 发送通知，有观测者处理失败时返回错误，由调用方记录未扫交易单
 func (bs *BlockScanner) newExtractDataNotify(height uint64, extractData map[string][]*openwallet.TxExtractData) error {
+	var notifyErr error
 	for o := range bs.Observers {
 		for key, array := range extractData {
 			for _, item := range array {
 				err := o.BlockExtractDataNotify(key, item)
 				if err != nil {
 					log.Error("BlockExtractDataNotify unexpected error:", err)
-					//记录未扫区块
-					unscanRecord := openwallet.NewUnscanRecord(height, "", "ExtractData Notify failed.", bs.wm.Symbol())
-					err = bs.SaveUnscanRecord(unscanRecord)
-					if err != nil {
-						log.Std.Error("block height: %d, save unscan record failed. unexpected error: %v", height, err.Error())
-					}
-
+					notifyErr = err
 				}
 			}
 
 		}
 	}
 
-	return nil
+	for key, array := range extractData {
+		for _, item := range array {
+			bs.ConfirmTracker.Track(key, item)
+		}
+	}
+
+	return notifyErr
 }
 
 //ScanBlock 扫描指定高度区块
@@ -645,8 +1023,8 @@ func (bs *BlockScanner) GetGlobalMaxBlockHeight() uint64 {
 func (bs *BlockScanner) GetGlobalHeadBlock() (block *Block, err error) {
 
 	height := bs.GetGlobalMaxBlockHeight()
-	if height == 0 {
-		bs.wm.Log.Std.Info("block scanner can not get height; unexpected error:%v", err)
+	if height <= 1 {
+		err = fmt.Errorf("block scanner can not get height of node")
 		return
 	}
 
@@ -690,7 +1068,7 @@ func (bs *BlockScanner) GetBalanceByAddress(address ...string) ([]*openwallet.Ba
 			bs.wm.Log.Errorf("get account[%v] token balance failed, err: %v", addr, err)
 		}
 
-		value := decimal.New(int64(balance), -bs.wm.Decimal())
+		value := utils.RawToDecimal(balance, bs.wm.Decimal())
 
 		tokenBalance := &openwallet.Balance{
 			Address:          addr,
@@ -716,10 +1094,11 @@ func (bs *BlockScanner) GetCurrentBlockHeader() (*openwallet.BlockHeader, error)
 }
 
 //rescanFailedRecord 重扫失败记录
+//txid为空的记录重扫整个区块，否则只重扫记录的交易单，成功的记录逐条删除
 func (bs *BlockScanner) RescanFailedRecord() {
 
 	var (
-		blockMap = make(map[uint64][]string)
+		blockMap = make(map[uint64][]*openwallet.UnscanRecord)
 	)
 
 	list, err := bs.BlockchainDAI.GetUnscanRecords(bs.wm.Symbol())
@@ -729,20 +1108,10 @@ func (bs *BlockScanner) RescanFailedRecord() {
 
 	//组合成批处理
 	for _, r := range list {
-
-		if _, exist := blockMap[r.BlockHeight]; !exist {
-			blockMap[r.BlockHeight] = make([]string, 0)
-		}
-
-		if len(r.TxID) > 0 {
-			arr := blockMap[r.BlockHeight]
-			arr = append(arr, r.TxID)
-
-			blockMap[r.BlockHeight] = arr
-		}
+		blockMap[r.BlockHeight] = append(blockMap[r.BlockHeight], r)
 	}
 
-	for height, _ := range blockMap {
+	for height, records := range blockMap {
 
 		if height == 0 {
 			continue
@@ -756,14 +1125,53 @@ func (bs *BlockScanner) RescanFailedRecord() {
 			continue
 		}
 
-		err = bs.BatchExtractTransactions(height, block.ID, block.Timestamp)
+		transactions, err := bs.GetBlockTransactions(block.ID)
 		if err != nil {
-			bs.wm.Log.Std.Info("block scanner can not extractRechargeRecords; unexpected error: %v", err)
+			bs.wm.Log.Std.Info("block scanner can not get block transactions; unexpected error: %v", err)
 			continue
 		}
 
-		//删除未扫记录
-		bs.DeleteUnscanRecord(uint32(height))
+		wholeBlock := false
+		txids := make(map[string]bool)
+		for _, r := range records {
+			if len(r.TxID) == 0 {
+				wholeBlock = true
+			} else {
+				txids[r.TxID] = true
+			}
+		}
+
+		if !wholeBlock {
+			rescanTxs := make([]*rpc.Transaction, 0, len(txids))
+			for _, trx := range transactions {
+				if txids[trx.ID] {
+					rescanTxs = append(rescanTxs, trx)
+				}
+			}
+			transactions = rescanTxs
+		}
+
+		//失败的交易单会重新记录，记录id由高度和txid决定
+		failedTxIDs, err := bs.extractTransactions(height, block.ID, block.Timestamp, transactions)
+		if err != nil {
+			bs.wm.Log.Std.Info("block scanner can not extractRechargeRecords; unexpected error: %v", err)
+		}
+
+		failed := make(map[string]bool)
+		for _, txid := range failedTxIDs {
+			failed[txid] = true
+		}
+
+		//删除已成功的未扫记录
+		for _, r := range records {
+			if len(r.TxID) > 0 && failed[r.TxID] {
+				continue
+			}
+			err = bs.DeleteUnscanRecordByID(r.ID)
+			if err != nil {
+				bs.wm.Log.Std.Info("block scanner delete unscan record: %s failed; unexpected error: %v", r.ID, err)
+			}
+		}
 	}
 }
 
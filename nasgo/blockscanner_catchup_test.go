@@ -0,0 +1,231 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//catchUpTestNode 模拟节点的区块链，高度越低的区块响应越慢，使预取乱序完成
+type catchUpTestNode struct {
+	mu        sync.Mutex
+	height    uint64          //节点高度，最新区块为height-1
+	blockTime int64           //区块时间戳
+	forkAt    uint64          //该高度的前块hash不连续
+	failAt    map[uint64]bool //该高度的区块查询失败
+	failOnce  map[uint64]bool //该高度的区块第一次查询失败
+	queried   map[uint64]int
+	*httptest.Server
+}
+
+func newCatchUpTestNode(height uint64, blockTime int64) *catchUpTestNode {
+	node := &catchUpTestNode{
+		height:    height,
+		blockTime: blockTime,
+		failAt:    make(map[uint64]bool),
+		failOnce:  make(map[uint64]bool),
+		queried:   make(map[uint64]int),
+	}
+	node.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/blocks/getHeight":
+			fmt.Fprintf(w, `{"success":true,"height":%d}`, node.height)
+		case "/api/blocks/getStatus":
+			fmt.Fprintf(w, `{"success":true,"height":%d}`, node.height-1)
+		case "/api/loader/status/sync":
+			fmt.Fprint(w, `{"success":true,"syncing":false}`)
+		case "/api/peers":
+			fmt.Fprint(w, `{"success":true,"totalCount":3}`)
+		case "/api/blocks/get":
+			height, _ := strconv.ParseUint(r.URL.Query().Get("height"), 10, 64)
+			time.Sleep(time.Duration(10-height%10) * time.Millisecond)
+
+			node.mu.Lock()
+			node.queried[height]++
+			fail := node.failAt[height] || (node.failOnce[height] && node.queried[height] == 1)
+			node.mu.Unlock()
+			if fail {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, `{"success":false,"error":"block is not ready"}`)
+				return
+			}
+
+			prev := fmt.Sprintf("block%d", height-1)
+			if height == node.forkAt {
+				prev = "forked"
+			}
+			fmt.Fprintf(w, `{"success":true,"block":{"id":"block%d","height":%d,"timestamp":%d,"previousBlock":"%s"}}`,
+				height, height, node.blockTime, prev)
+		case "/api/transactions":
+			height := strings.TrimPrefix(r.URL.Query().Get("blockId"), "block")
+			fmt.Fprintf(w, `{"success":true,"count":1,"transactions":[{"id":"tx%s","type":%d,"senderId":"NKkZ1JhkLZ8PXnF5TLmRoAgXs4gGLXzvbW","recipientId":"%s","amount":100000000,"fee":10000000}]}`,
+				height, rpc.TxType_NSG, rangeTestRecipient)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return node
+}
+
+func (node *catchUpTestNode) queriedOf(height uint64) int {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	return node.queried[height]
+}
+
+//scanRecorder 扫描器观测者，按通知顺序记录txid，fail中的txid通知失败
+type scanRecorder struct {
+	mu       sync.Mutex
+	fail     map[string]bool
+	notified []string
+}
+
+func (r *scanRecorder) BlockScanNotify(header *openwallet.BlockHeader) error {
+	return nil
+}
+
+func (r *scanRecorder) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	txid := data.Transaction.TxID
+	r.notified = append(r.notified, txid)
+	if r.fail[txid] {
+		return fmt.Errorf("notify %s failed", txid)
+	}
+	return nil
+}
+
+func (r *scanRecorder) BlockExtractSmartContractDataNotify(sourceKey string, data *openwallet.SmartContractReceipt) error {
+	return nil
+}
+
+func (r *scanRecorder) txids() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.notified...)
+}
+
+func heightsBetween(from, to uint64) []uint64 {
+	var heights []uint64
+	for h := from; h <= to; h++ {
+		heights = append(heights, h)
+	}
+	return heights
+}
+
+func TestBlockScanner_CatchUpBlocks(t *testing.T) {
+	tests := []struct {
+		name       string
+		forkAt     uint64
+		failAt     uint64
+		wantHeight uint32
+		wantHeads  []uint64
+	}{
+		{name: "full window", wantHeight: 54, wantHeads: heightsBetween(51, 54)},
+		{name: "prev hash mismatch stops window", forkAt: 53, wantHeight: 52, wantHeads: heightsBetween(51, 52)},
+		{name: "prefetch error stops window", failAt: 52, wantHeight: 51, wantHeads: heightsBetween(51, 51)},
+		{name: "prefetch error on first block", failAt: 51, wantHeight: 50, wantHeads: heightsBetween(51, 50)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := newCatchUpTestNode(62, 1000)
+			defer node.Close()
+			node.forkAt = tt.forkAt
+			node.failAt[tt.failAt] = true
+
+			bs, dai := newRangeTestScanner(node.URL, 4)
+			bs.Scanning = true
+			bs.ScanTargetFunc = scanTargetOf(map[string]string{rangeTestRecipient: "account"})
+			recorder := &scanRecorder{}
+			bs.AddObserver(recorder)
+
+			height, hash := bs.catchUpBlocks(50, "block50", 4)
+			wantHash := fmt.Sprintf("block%d", tt.wantHeight)
+			if height != tt.wantHeight || hash != wantHash {
+				t.Errorf("catchUpBlocks() = %d %s, want %d %s", height, hash, tt.wantHeight, wantHash)
+			}
+
+			//预取乱序完成，提交仍按高度顺序
+			if !reflect.DeepEqual(dai.heads, tt.wantHeads) {
+				t.Errorf("committed heights = %v, want %v", dai.heads, tt.wantHeads)
+			}
+			wantTxIDs := make([]string, 0)
+			for _, h := range tt.wantHeads {
+				wantTxIDs = append(wantTxIDs, fmt.Sprintf("tx%d", h))
+			}
+			if got := recorder.txids(); !reflect.DeepEqual(got, wantTxIDs) {
+				t.Errorf("notified txids = %v, want %v", got, wantTxIDs)
+			}
+		})
+	}
+}
+
+func TestBlockScanner_ScanBlockTask_CatchUp(t *testing.T) {
+	tests := []struct {
+		name        string
+		failOnce    uint64
+		wantQueried map[uint64]int
+	}{
+		{
+			//窗口51-54、55-58，剩余3个区块单块扫描
+			name:        "switch back near tip",
+			wantQueried: map[uint64]int{51: 1, 55: 1, 59: 1, 61: 2, 62: 0},
+		},
+		{
+			//窗口第一个区块失败，单块重新获取51后继续追块
+			name:        "prefetch error falls back to single block",
+			failOnce:    51,
+			wantQueried: map[uint64]int{51: 2, 52: 2, 55: 1, 56: 1, 61: 2, 62: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm := NewWalletManager()
+			node := newCatchUpTestNode(62, wm.Config.GetEpochTime())
+			defer node.Close()
+			node.failOnce[tt.failOnce] = true
+
+			bs, dai := newRangeTestScanner(node.URL, 4)
+			dai.head.Hash = "block50"
+			bs.Scanning = true
+			bs.ScanTargetFunc = scanTargetOf(map[string]string{rangeTestRecipient: "account"})
+
+			bs.ScanBlockTask()
+
+			if want := heightsBetween(51, 61); !reflect.DeepEqual(dai.heads, want) {
+				t.Errorf("committed heights = %v, want %v", dai.heads, want)
+			}
+			for height, want := range tt.wantQueried {
+				if got := node.queriedOf(height); got != want {
+					t.Errorf("block %d queried %d times, want %d", height, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/nasgo-adapter/utils"
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//UIA合约事件
+const (
+	ContractEventTransfer = "transfer" //资产转账
+	ContractEventIssue    = "issue"    //资产增发
+	ContractEventRegister = "register" //资产注册
+)
+
+//uiaContractEvent UIA合约事件内容
+type uiaContractEvent struct {
+	From       string `json:"from"`
+	To         string `json:"to,omitempty"`
+	Currency   string `json:"currency"`
+	Amount     string `json:"amount"`     //链上整数数量
+	AmountShow string `json:"amountShow"` //按精度换算的数量
+	Precision  uint64 `json:"precision"`
+}
+
+//ExtractSmartContractReceipt 提取UIA转账、增发和注册的合约回执，只提取scanTargetFuncV2订阅的合约
+//资产信息缺失或查询精度失败时返回错误
+func (bs *BlockScanner) ExtractSmartContractReceipt(trx *rpc.Transaction, result *ExtractResult, scanTargetFuncV2 openwallet.BlockScanTargetFuncV2) error {
+
+	if scanTargetFuncV2 == nil || trx.Asset == nil {
+		return nil
+	}
+
+	var (
+		currency  string
+		event     uiaContractEvent
+		eventName string
+	)
+
+	event.From = trx.SenderID
+
+	switch trx.Type {
+	case rpc.TxType_Asset:
+		transfer := trx.Asset.UiaTransfer
+		if transfer == nil {
+			return fmt.Errorf("transaction [%s] uia transfer info missing", trx.ID)
+		}
+		currency = transfer.Currency
+		eventName = ContractEventTransfer
+		event.To = trx.RecipientId
+		event.Amount = transfer.Amount
+		event.Precision = uint64(transfer.Precision)
+	case rpc.TxType_IssueAsset:
+		issue := trx.Asset.UiaIssue
+		if issue == nil {
+			return fmt.Errorf("transaction [%s] uia issue info missing", trx.ID)
+		}
+		currency = issue.Currency
+		eventName = ContractEventIssue
+		event.To = trx.SenderID
+		event.Amount = issue.Amount
+	case rpc.TxType_RegAsset:
+		asset := trx.Asset.UiaAsset
+		if asset == nil {
+			return fmt.Errorf("transaction [%s] uia asset info missing", trx.ID)
+		}
+		currency = asset.Name
+		eventName = ContractEventRegister
+		event.Amount = asset.Maximum
+		event.Precision = uint64(asset.Precision)
+	default:
+		return nil
+	}
+
+	target := scanTargetFuncV2(openwallet.ScanTargetParam{
+		ScanTarget:     currency,
+		Symbol:         bs.wm.Symbol(),
+		ScanTargetType: openwallet.ScanTargetTypeContractAddress,
+	})
+	if !target.Exist {
+		return nil
+	}
+
+	//增发交易单不带精度，从链上查询
+	if trx.Type == rpc.TxType_IssueAsset {
+		metadata, err := bs.wm.ContractDecoder.GetTokenMetadata(currency)
+		if err != nil {
+			return fmt.Errorf("can not get token [%s] metadata, unexpected error: %v", currency, err)
+		}
+		event.Precision = metadata.Precision
+	}
+
+	event.Currency = currency
+	amount, err := utils.ParseRawAmount(event.Amount)
+	if err == nil {
+		amount, err = utils.FromRawAmount(amount, int32(event.Precision))
+	}
+	if err != nil {
+		return fmt.Errorf("transaction [%s] uia amount: %v", trx.ID, err)
+	}
+	event.AmountShow = amount.String()
+
+	contractID := openwallet.GenContractID(bs.wm.Symbol(), currency)
+	contract := &openwallet.SmartContract{
+		ContractID: contractID,
+		Symbol:     bs.wm.Symbol(),
+		Address:    currency,
+		Token:      currency,
+		Decimals:   event.Precision,
+	}
+	if info, ok := target.TargetInfo.(*openwallet.SmartContract); ok && info != nil {
+		contract.Name = info.Name
+	}
+
+	rawReceipt, _ := json.Marshal(trx.Asset)
+	eventValue, _ := json.Marshal(event)
+
+	receipt := &openwallet.SmartContractReceipt{
+		Coin: openwallet.Coin{
+			Symbol:     bs.wm.Symbol(),
+			IsContract: true,
+			ContractID: contractID,
+			Contract:   *contract,
+		},
+		TxID:        trx.ID,
+		From:        trx.SenderID,
+		To:          currency,
+		Value:       utils.RawToDecimal(trx.Amount, bs.wm.Decimal()).String(),
+		Fees:        utils.RawToDecimal(trx.Fee, bs.wm.Decimal()).String(),
+		RawReceipt:  string(rawReceipt),
+		Events:      []*openwallet.SmartContractEvent{{Contract: contract, Event: eventName, Value: string(eventValue)}},
+		BlockHash:   result.BlockHash,
+		BlockHeight: result.BlockHeight,
+		ConfirmTime: result.BlockTime,
+		Status:      "1",
+	}
+	receipt.GenWxID()
+
+	extParam, _ := json.Marshal(map[string]interface{}{
+		"txType":     trx.Type,
+		"txTypeName": rpc.TxTypeName(trx.Type),
+		"memo":       trx.Message,
+	})
+	receipt.ExtParam = string(extParam)
+
+	result.extractContractData[contractID] = append(result.extractContractData[contractID], receipt)
+
+	return nil
+}
+
+//ExtractTransactionAndReceiptData 提取交易单及交易回执数据
+func (bs *BlockScanner) ExtractTransactionAndReceiptData(txid string, scanTargetFunc openwallet.BlockScanTargetFuncV2) (map[string][]*openwallet.TxExtractData, map[string]*openwallet.SmartContractReceipt, error) {
+
+	if scanTargetFunc == nil {
+		return nil, nil, fmt.Errorf("scanTargetFunc is not configurated")
+	}
+
+	//按txid查询全部类型的交易单，UIA转账再补充资产明细
+	trx, err := bs.wm.WalletClient.Tx.GetTransactionByID(txid)
+	if err != nil {
+		return nil, nil, err
+	}
+	if trx == nil {
+		return nil, nil, fmt.Errorf("transaction [%s] not found", txid)
+	}
+	if trx.Type == rpc.TxType_Asset {
+		assetTx, err := bs.wm.WalletClient.Tx.GetTransaction(txid)
+		if err != nil {
+			return nil, nil, err
+		}
+		if assetTx.Asset == nil || assetTx.Asset.UiaTransfer == nil {
+			return nil, nil, fmt.Errorf("asset transaction [%s] detail is missing", txid)
+		}
+		trx.Asset = assetTx.Asset
+	}
+
+	height, err := strconv.ParseUint(trx.Height, 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("transaction [%s] invalid height: %s", txid, trx.Height)
+	}
+
+	block, err := bs.wm.WalletClient.Block.GetByHeight(height)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	//地址订阅使用scanTargetFunc查找账户
+	addressTargetFunc := func(target openwallet.ScanTarget) (string, bool) {
+		result := scanTargetFunc(openwallet.ScanTargetParam{
+			ScanTarget:     target.Address,
+			Symbol:         target.Symbol,
+			ScanTargetType: openwallet.ScanTargetTypeAccountAddress,
+		})
+		return result.SourceKey, result.Exist
+	}
+
+	result := bs.extractTransaction(block.Height, block.ID, block.Timestamp, trx, addressTargetFunc, scanTargetFunc)
+	if !result.Success {
+		return nil, nil, fmt.Errorf("extract transaction [%s] failed", txid)
+	}
+
+	receipts := make(map[string]*openwallet.SmartContractReceipt)
+	for contractID, array := range result.extractContractData {
+		for _, receipt := range array {
+			receipts[contractID] = receipt
+		}
+	}
+
+	return result.extractData, receipts, nil
+}
+
+//newExtractContractDataNotify 发送合约回执通知，有观测者处理失败时返回错误
+func (bs *BlockScanner) newExtractContractDataNotify(height uint64, extractContractData map[string][]*openwallet.SmartContractReceipt) error {
+	var notifyErr error
+	for o := range bs.Observers {
+		for key, array := range extractContractData {
+			for _, item := range array {
+				err := o.BlockExtractSmartContractDataNotify(key, item)
+				if err != nil {
+					log.Error("BlockExtractSmartContractDataNotify unexpected error:", err)
+					notifyErr = err
+				}
+			}
+		}
+	}
+
+	return notifyErr
+}
@@ -111,6 +111,16 @@ func (bs *BlockScanner) DeleteUnscanRecord(height uint32) error {
 	return bs.BlockchainDAI.DeleteUnscanRecordByHeight(uint64(height), bs.wm.Symbol())
 }
 
+//DeleteUnscanRecordByID 删除指定id的未扫记录
+func (bs *BlockScanner) DeleteUnscanRecordByID(id string) error {
+
+	if bs.BlockchainDAI == nil {
+		return fmt.Errorf("Blockchain DAI is not setup ")
+	}
+
+	return bs.BlockchainDAI.DeleteUnscanRecordByID(id, bs.wm.Symbol())
+}
+
 func (bs *BlockScanner) GetUnscanRecords() ([]*openwallet.UnscanRecord, error) {
 
 	if bs.BlockchainDAI == nil {
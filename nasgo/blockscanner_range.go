@@ -0,0 +1,109 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//ScanBlockRangeFunc 区块范围扫描结果回调
+type ScanBlockRangeFunc func(height uint64, sourceKey string, data *openwallet.TxExtractData) error
+
+//scanBlockRangeRecord 写入writer的一行结果
+type scanBlockRangeRecord struct {
+	Height    uint64                    `json:"height"`
+	SourceKey string                    `json:"sourceKey"`
+	Data      *openwallet.TxExtractData `json:"data"`
+}
+
+//NewScanBlockRangeWriter 把区块范围扫描结果按行写入JSON
+func NewScanBlockRangeWriter(w io.Writer) ScanBlockRangeFunc {
+	var mu sync.Mutex
+	encoder := json.NewEncoder(w)
+	return func(height uint64, sourceKey string, data *openwallet.TxExtractData) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return encoder.Encode(&scanBlockRangeRecord{
+			Height:    height,
+			SourceKey: sourceKey,
+			Data:      data,
+		})
+	}
+}
+
+//ScanBlockRange 扫描[from, to]高度范围内scanTargetFunc匹配的交易，结果交给handler
+//不修改本地区块头，不通知观测者，不记录未扫区块，用于新导入地址的历史补扫
+func (bs *BlockScanner) ScanBlockRange(from, to uint64, scanTargetFunc openwallet.BlockScanTargetFunc, handler ScanBlockRangeFunc) error {
+
+	if from == 0 || from > to {
+		return fmt.Errorf("invalid block range: %d - %d", from, to)
+	}
+
+	if scanTargetFunc == nil {
+		return fmt.Errorf("scanTargetFunc is not configurated")
+	}
+
+	if handler == nil {
+		return fmt.Errorf("handler of scan result is nil")
+	}
+
+	window := bs.wm.Config.CatchUpWindow
+	if window < 1 {
+		window = 1
+	}
+
+	for height := from; height <= to; height += uint64(window) {
+
+		count := window
+		if remain := to - height + 1; remain < uint64(count) {
+			count = int(remain)
+		}
+
+		prefetched := bs.prefetchBlocks(uint32(height), count)
+
+		for i, p := range prefetched {
+			blockHeight := height + uint64(i)
+			if p.err != nil {
+				return fmt.Errorf("block height: %d can not get block data; unexpected error: %v", blockHeight, p.err)
+			}
+
+			block := p.block
+			for _, trx := range p.transactions {
+				result := bs.ExtractTransaction(block.Height, block.ID, block.Timestamp, trx, scanTargetFunc)
+				if !result.Success {
+					return fmt.Errorf("block height: %d extract transaction [%s] failed", blockHeight, trx.ID)
+				}
+
+				for sourceKey, array := range result.extractData {
+					for _, item := range array {
+						if err := handler(block.Height, sourceKey, item); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+
+		bs.wm.Log.Std.Info("block scanner range scanned height: %d - %d", height, height+uint64(count)-1)
+	}
+
+	return nil
+}
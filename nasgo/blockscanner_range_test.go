@@ -0,0 +1,257 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+const rangeTestRecipient = "NEXaVgHXGdrAW5i1wyyxBJrVJhH3HdmD7f"
+
+//rangeTestBlockchain 记录区块扫描器对本地数据的写入
+type rangeTestBlockchain struct {
+	openwallet.BlockchainDAIBase
+	mu      sync.Mutex
+	head    *openwallet.BlockHeader
+	heads   []uint64 //按保存顺序记录的扫描高度
+	records map[string]*openwallet.UnscanRecord
+	writes  int
+}
+
+func (dai *rangeTestBlockchain) SaveCurrentBlockHead(header *openwallet.BlockHeader) error {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	dai.head = header
+	dai.heads = append(dai.heads, header.Height)
+	dai.writes++
+	return nil
+}
+
+func (dai *rangeTestBlockchain) GetCurrentBlockHead(symbol string) (*openwallet.BlockHeader, error) {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	return dai.head, nil
+}
+
+func (dai *rangeTestBlockchain) SaveLocalBlockHead(header *openwallet.BlockHeader) error {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	dai.writes++
+	return nil
+}
+
+func (dai *rangeTestBlockchain) SaveUnscanRecord(record *openwallet.UnscanRecord) error {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	if dai.records == nil {
+		dai.records = make(map[string]*openwallet.UnscanRecord)
+	}
+	dai.records[record.ID] = record
+	dai.writes++
+	return nil
+}
+
+func (dai *rangeTestBlockchain) GetUnscanRecords(symbol string) ([]*openwallet.UnscanRecord, error) {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	list := make([]*openwallet.UnscanRecord, 0, len(dai.records))
+	for _, r := range dai.records {
+		list = append(list, r)
+	}
+	return list, nil
+}
+
+func (dai *rangeTestBlockchain) DeleteUnscanRecordByID(id string, symbol string) error {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	delete(dai.records, id)
+	return nil
+}
+
+//newRangeTestNode 每个区块有一笔转给rangeTestRecipient的NSG交易，返回已查询的区块高度
+func newRangeTestNode() (*httptest.Server, func() map[string]int) {
+	var (
+		mu      sync.Mutex
+		queried = make(map[string]int)
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/blocks/get":
+			height := r.URL.Query().Get("height")
+			mu.Lock()
+			queried[height]++
+			mu.Unlock()
+			fmt.Fprintf(w, `{"success":true,"block":{"id":"block%s","height":%s,"timestamp":1000}}`, height, height)
+		case "/api/transactions":
+			height := strings.TrimPrefix(r.URL.Query().Get("blockId"), "block")
+			fmt.Fprintf(w, `{"success":true,"count":1,"transactions":[{"id":"tx%s","type":%d,"senderId":"NKkZ1JhkLZ8PXnF5TLmRoAgXs4gGLXzvbW","recipientId":"%s","amount":100000000,"fee":10000000}]}`,
+				height, rpc.TxType_NSG, rangeTestRecipient)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return server, func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+		result := make(map[string]int)
+		for k, v := range queried {
+			result[k] = v
+		}
+		return result
+	}
+}
+
+func newRangeTestScanner(serverURL string, window int) (*BlockScanner, *rangeTestBlockchain) {
+	wm := NewWalletManager()
+	wm.WalletClient = rpc.NewClient(serverURL)
+	wm.Config.CatchUpWindow = window
+	dai := &rangeTestBlockchain{head: &openwallet.BlockHeader{Height: 50, Hash: "head50", Symbol: wm.Symbol()}}
+	wm.Blockscanner.BlockchainDAI = dai
+	return wm.Blockscanner, dai
+}
+
+func TestBlockScanner_ScanBlockRange_Bounds(t *testing.T) {
+	server, queried := newRangeTestNode()
+	defer server.Close()
+
+	bs, _ := newRangeTestScanner(server.URL, 3)
+	scanTarget := scanTargetOf(map[string]string{rangeTestRecipient: "account"})
+	handler := func(height uint64, sourceKey string, data *openwallet.TxExtractData) error { return nil }
+
+	if err := bs.ScanBlockRange(0, 5, scanTarget, handler); err == nil {
+		t.Errorf("ScanBlockRange() from 0 should fail")
+	}
+	if err := bs.ScanBlockRange(6, 5, scanTarget, handler); err == nil {
+		t.Errorf("ScanBlockRange() from > to should fail")
+	}
+	if len(queried()) != 0 {
+		t.Errorf("invalid range queried blocks: %v", queried())
+	}
+
+	//最后一个窗口只有1个区块，不能越过to
+	var heights []uint64
+	err := bs.ScanBlockRange(1, 7, scanTarget, func(height uint64, sourceKey string, data *openwallet.TxExtractData) error {
+		if sourceKey != "account" {
+			t.Errorf("sourceKey = %s, want account", sourceKey)
+		}
+		heights = append(heights, height)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanBlockRange() failed: %v", err)
+	}
+
+	if len(heights) != 7 {
+		t.Fatalf("handled heights = %v, want 1 - 7", heights)
+	}
+	for i, height := range heights {
+		if height != uint64(i+1) {
+			t.Errorf("handled heights = %v, want in order 1 - 7", heights)
+			break
+		}
+	}
+
+	got := queried()
+	if len(got) != 7 {
+		t.Errorf("queried blocks = %v, want 1 - 7", got)
+	}
+	for height, count := range got {
+		if count != 1 {
+			t.Errorf("block %s queried %d times", height, count)
+		}
+	}
+
+	//单个区块的范围
+	heights = nil
+	err = bs.ScanBlockRange(9, 9, scanTarget, func(height uint64, sourceKey string, data *openwallet.TxExtractData) error {
+		heights = append(heights, height)
+		return nil
+	})
+	if err != nil || len(heights) != 1 || heights[0] != 9 {
+		t.Errorf("ScanBlockRange(9, 9) heights = %v, err = %v", heights, err)
+	}
+}
+
+func TestBlockScanner_ScanBlockRange_HandlerError(t *testing.T) {
+	server, queried := newRangeTestNode()
+	defer server.Close()
+
+	bs, _ := newRangeTestScanner(server.URL, 2)
+	scanTarget := scanTargetOf(map[string]string{rangeTestRecipient: "account"})
+
+	var heights []uint64
+	err := bs.ScanBlockRange(1, 10, scanTarget, func(height uint64, sourceKey string, data *openwallet.TxExtractData) error {
+		heights = append(heights, height)
+		if height == 3 {
+			return fmt.Errorf("handler failed")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "handler failed" {
+		t.Fatalf("ScanBlockRange() error = %v, want handler failed", err)
+	}
+	if len(heights) != 3 || heights[2] != 3 {
+		t.Errorf("handled heights = %v, want 1 - 3", heights)
+	}
+
+	//出错的窗口之后不再查询区块
+	if got := queried(); len(got) != 4 {
+		t.Errorf("queried blocks = %v, want 1 - 4", got)
+	}
+}
+
+func TestBlockScanner_ScanBlockRange_KeepHead(t *testing.T) {
+	server, _ := newRangeTestNode()
+	defer server.Close()
+
+	bs, dai := newRangeTestScanner(server.URL, 4)
+
+	var buf bytes.Buffer
+	err := bs.ScanBlockRange(10, 15, scanTargetOf(map[string]string{rangeTestRecipient: "account"}), NewScanBlockRangeWriter(&buf))
+	if err != nil {
+		t.Fatalf("ScanBlockRange() failed: %v", err)
+	}
+
+	height, hash, err := bs.GetLocalBlockHead()
+	if err != nil || height != 50 || hash != "head50" {
+		t.Errorf("local block head = %d %s, want 50 head50, err = %v", height, hash, err)
+	}
+	if dai.writes != 0 {
+		t.Errorf("ScanBlockRange() wrote local block data %d times", dai.writes)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("writer lines = %d, want 6", len(lines))
+	}
+	var record scanBlockRangeRecord
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("decode writer line failed: %v", err)
+	}
+	if record.Height != 10 || record.SourceKey != "account" || record.Data.Transaction.TxID != "tx10" {
+		t.Errorf("writer record = %s", lines[0])
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+var rescanTestTxIDs = []string{"tx60a", "tx60b", "tx60c"}
+
+//newRescanTestNode 高度60的区块有3笔转给rangeTestRecipient的NSG交易
+func newRescanTestNode() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/blocks/get":
+			fmt.Fprint(w, `{"success":true,"block":{"id":"block60","height":60,"timestamp":1000,"previousBlock":"block59"}}`)
+		case "/api/transactions":
+			txs := make([]string, 0, len(rescanTestTxIDs))
+			for _, txid := range rescanTestTxIDs {
+				txs = append(txs, fmt.Sprintf(`{"id":"%s","type":%d,"senderId":"NKkZ1JhkLZ8PXnF5TLmRoAgXs4gGLXzvbW","recipientId":"%s","amount":100000000,"fee":10000000}`,
+					txid, rpc.TxType_NSG, rangeTestRecipient))
+			}
+			fmt.Fprintf(w, `{"success":true,"count":%d,"transactions":[%s]}`, len(txs), strings.Join(txs, ","))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func newRescanTestScanner(serverURL string, fail ...string) (*BlockScanner, *rangeTestBlockchain, *scanRecorder) {
+	bs, dai := newRangeTestScanner(serverURL, 0)
+	bs.ScanTargetFunc = scanTargetOf(map[string]string{rangeTestRecipient: "account"})
+	recorder := &scanRecorder{fail: make(map[string]bool)}
+	for _, txid := range fail {
+		recorder.fail[txid] = true
+	}
+	bs.AddObserver(recorder)
+	return bs, dai, recorder
+}
+
+//unscanTxIDs 剩余的未扫记录，格式为高度/txid，整块记录的txid为空
+func unscanTxIDs(dai *rangeTestBlockchain) []string {
+	list, _ := dai.GetUnscanRecords("")
+	txids := make([]string, 0, len(list))
+	for _, r := range list {
+		txids = append(txids, fmt.Sprintf("%d/%s", r.BlockHeight, r.TxID))
+	}
+	sort.Strings(txids)
+	return txids
+}
+
+func TestBlockScanner_ExtractTransactions_FailedTxIDs(t *testing.T) {
+	server := newRescanTestNode()
+	defer server.Close()
+
+	bs, dai, recorder := newRescanTestScanner(server.URL, "tx60b")
+
+	transactions, err := bs.GetBlockTransactions("block60")
+	if err != nil {
+		t.Fatalf("GetBlockTransactions() failed: %v", err)
+	}
+
+	failedTxIDs, err := bs.extractTransactions(60, "block60", 1000, transactions)
+	if err == nil {
+		t.Errorf("extractTransactions() want error when observer failed")
+	}
+	if !reflect.DeepEqual(failedTxIDs, []string{"tx60b"}) {
+		t.Errorf("failedTxIDs = %v, want [tx60b]", failedTxIDs)
+	}
+	if got := recorder.txids(); len(got) != 3 {
+		t.Errorf("notified txids = %v, want all", got)
+	}
+
+	//只记录通知失败的交易单
+	if got, want := unscanTxIDs(dai), []string{"60/tx60b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unscan records = %v, want %v", got, want)
+	}
+}
+
+func TestBlockScanner_RescanFailedRecord(t *testing.T) {
+	tests := []struct {
+		name         string
+		records      []string //未扫记录的txid，空字符串为整块记录
+		fail         []string //观测者仍通知失败的txid
+		wantNotified []string
+		wantRecords  []string
+	}{
+		{
+			name:         "only listed transactions are notified",
+			records:      []string{"tx60a", "tx60b"},
+			wantNotified: []string{"tx60a", "tx60b"},
+			wantRecords:  []string{},
+		},
+		{
+			name:         "record kept while notify still fails",
+			records:      []string{"tx60a", "tx60b"},
+			fail:         []string{"tx60b"},
+			wantNotified: []string{"tx60a", "tx60b"},
+			wantRecords:  []string{"60/tx60b"},
+		},
+		{
+			name:         "whole block record narrows to failed transaction",
+			records:      []string{""},
+			fail:         []string{"tx60c"},
+			wantNotified: rescanTestTxIDs,
+			wantRecords:  []string{"60/tx60c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRescanTestNode()
+			defer server.Close()
+
+			bs, dai, recorder := newRescanTestScanner(server.URL, tt.fail...)
+			for _, txid := range tt.records {
+				dai.SaveUnscanRecord(openwallet.NewUnscanRecord(60, txid, "notify failed", bs.wm.Symbol()))
+			}
+
+			bs.RescanFailedRecord()
+
+			notified := recorder.txids()
+			sort.Strings(notified)
+			if !reflect.DeepEqual(notified, tt.wantNotified) {
+				t.Errorf("notified txids = %v, want %v", notified, tt.wantNotified)
+			}
+			if got := unscanTxIDs(dai); !reflect.DeepEqual(got, tt.wantRecords) {
+				t.Errorf("unscan records = %v, want %v", got, tt.wantRecords)
+			}
+		})
+	}
+}
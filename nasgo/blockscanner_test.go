@@ -0,0 +1,374 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func scanTargetOf(addresses map[string]string) openwallet.BlockScanTargetFunc {
+	return func(target openwallet.ScanTarget) (string, bool) {
+		accountID, ok := addresses[target.Address]
+		return accountID, ok
+	}
+}
+
+func TestBlockScanner_ExtractTransaction_Vote(t *testing.T) {
+	wm := NewWalletManager()
+	trx := &rpc.Transaction{
+		ID:       "5f2b7e0c",
+		Type:     rpc.TxType_Vote,
+		SenderID: "NKkZ1JhkLZ8PXnF5TLmRoAgXs4gGLXzvbW",
+		Fee:      10000000,
+		Asset: &rpc.Asset{
+			Vote: &rpc.Vote{Votes: []string{"+a1b2", "-c3d4"}},
+		},
+	}
+
+	result := wm.Blockscanner.ExtractTransaction(100, "blockhash", 0, trx, scanTargetOf(map[string]string{trx.SenderID: "account"}))
+	if !result.Success {
+		t.Fatalf("ExtractTransaction() failed")
+	}
+
+	array := result.extractData["account"]
+	if len(array) != 1 {
+		t.Fatalf("extract data count = %d, want 1", len(array))
+	}
+
+	data := array[0]
+	if len(data.TxInputs) != 1 || len(data.TxOutputs) != 0 {
+		t.Fatalf("inputs = %d, outputs = %d, want 1, 0", len(data.TxInputs), len(data.TxOutputs))
+	}
+	if data.TxInputs[0].Amount != "0.1" || data.TxInputs[0].Address != trx.SenderID {
+		t.Errorf("fee input = %s %s", data.TxInputs[0].Address, data.TxInputs[0].Amount)
+	}
+
+	ext := data.Transaction.GetExtParam()
+	if ext.Get("txTypeName").String() != "vote" || ext.Get("txType").Uint() != rpc.TxType_Vote {
+		t.Errorf("ext param txType = %s", ext.Raw)
+	}
+	if votes := ext.Get("votes").Array(); len(votes) != 2 || votes[0].String() != "+a1b2" {
+		t.Errorf("ext param votes = %s", ext.Get("votes").Raw)
+	}
+
+	//发送者未订阅时不提取
+	result = wm.Blockscanner.ExtractTransaction(100, "blockhash", 0, trx, scanTargetOf(map[string]string{}))
+	if !result.Success || len(result.extractData) != 0 {
+		t.Errorf("ExtractTransaction() of unsubscribed sender = %v", result.extractData)
+	}
+}
+
+func TestBlockScanner_ExtractSmartContractReceipt(t *testing.T) {
+	wm := NewWalletManager()
+	trx := &rpc.Transaction{
+		ID:          "9a8c1e3f",
+		Type:        rpc.TxType_Asset,
+		SenderID:    "NKkZ1JhkLZ8PXnF5TLmRoAgXs4gGLXzvbW",
+		RecipientId: "NEXaVgHXGdrAW5i1wyyxBJrVJhH3HdmD7f",
+		Fee:         10000000,
+		Asset: &rpc.Asset{
+			UiaTransfer: &rpc.UiaTransfer{Currency: "NSG.CNY", Amount: "123450000", Precision: 6},
+		},
+	}
+
+	scanTargetFuncV2 := func(target openwallet.ScanTargetParam) openwallet.ScanTargetResult {
+		exist := target.ScanTargetType == openwallet.ScanTargetTypeContractAddress && target.ScanTarget == "NSG.CNY"
+		return openwallet.ScanTargetResult{SourceKey: target.ScanTarget, Exist: exist}
+	}
+
+	result := wm.Blockscanner.extractTransaction(100, "blockhash", 0, trx, scanTargetOf(map[string]string{}), scanTargetFuncV2)
+	if !result.Success {
+		t.Fatalf("extractTransaction() failed")
+	}
+
+	contractID := openwallet.GenContractID(Symbol, "NSG.CNY")
+	receipts := result.extractContractData[contractID]
+	if len(receipts) != 1 {
+		t.Fatalf("receipt count = %d, want 1", len(receipts))
+	}
+
+	receipt := receipts[0]
+	if receipt.TxID != trx.ID || receipt.From != trx.SenderID || receipt.To != "NSG.CNY" || receipt.Fees != "0.1" {
+		t.Errorf("receipt = %+v", receipt)
+	}
+	if len(receipt.Events) != 1 || receipt.Events[0].Event != ContractEventTransfer {
+		t.Fatalf("receipt events = %v", receipt.Events)
+	}
+	event := gjson.Parse(receipt.Events[0].Value)
+	if event.Get("amountShow").String() != "123.45" || event.Get("to").String() != trx.RecipientId || event.Get("precision").Uint() != 6 {
+		t.Errorf("event value = %s", receipt.Events[0].Value)
+	}
+	if gjson.Get(receipt.RawReceipt, "uiaTransfer.currency").String() != "NSG.CNY" {
+		t.Errorf("raw receipt = %s", receipt.RawReceipt)
+	}
+
+	//未订阅的合约不提取
+	trx.Asset.UiaTransfer.Currency = "NSG.USD"
+	result = wm.Blockscanner.extractTransaction(100, "blockhash", 0, trx, scanTargetOf(map[string]string{}), scanTargetFuncV2)
+	if !result.Success || len(result.extractContractData) != 0 {
+		t.Errorf("extractTransaction() of unsubscribed contract = %v", result.extractContractData)
+	}
+}
+
+func TestBlockScanner_ExtractTransaction_TokenFilter(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.TokenAllowList = []string{"NSG.CNY"}
+	sender := "NKkZ1JhkLZ8PXnF5TLmRoAgXs4gGLXzvbW"
+	recipient := "NEXaVgHXGdrAW5i1wyyxBJrVJhH3HdmD7f"
+	trx := &rpc.Transaction{
+		ID:          "3c5d7e9f",
+		Type:        rpc.TxType_Asset,
+		SenderID:    sender,
+		RecipientId: recipient,
+		Fee:         10000000,
+		Asset: &rpc.Asset{
+			UiaTransfer: &rpc.UiaTransfer{Currency: "SPAM.COIN", Amount: "100", Precision: 2},
+		},
+	}
+	scanTarget := scanTargetOf(map[string]string{sender: "sender", recipient: "recipient"})
+
+	//跳过未登记资产，发送者只提取手续费
+	result := wm.Blockscanner.ExtractTransaction(100, "blockhash", 0, trx, scanTarget)
+	if !result.Success {
+		t.Fatalf("ExtractTransaction() failed")
+	}
+	if len(result.extractData["recipient"]) != 0 {
+		t.Errorf("recipient extract data = %d, want 0", len(result.extractData["recipient"]))
+	}
+	if array := result.extractData["sender"]; len(array) != 1 || array[0].Transaction.Coin.IsContract {
+		t.Errorf("sender extract data should only contain fee")
+	}
+	if len(result.unregisteredTokens) != 2 || result.unregisteredTokens[0].Currency != "SPAM.COIN" {
+		t.Errorf("unregistered tokens = %v", result.unregisteredTokens)
+	}
+
+	//标记未登记资产
+	wm.Config.TokenFilterAction = TokenFilterActionTag
+	result = wm.Blockscanner.ExtractTransaction(100, "blockhash", 0, trx, scanTarget)
+	array := result.extractData["recipient"]
+	if len(array) != 1 || !array[0].Transaction.GetExtParam().Get("unregisteredToken").Bool() {
+		t.Errorf("recipient extract data should be tagged")
+	}
+
+	//已登记资产不标记
+	trx.Asset.UiaTransfer.Currency = "NSG.CNY"
+	result = wm.Blockscanner.ExtractTransaction(100, "blockhash", 0, trx, scanTarget)
+	array = result.extractData["recipient"]
+	if len(array) != 1 || array[0].Transaction.GetExtParam().Get("unregisteredToken").Bool() || len(result.unregisteredTokens) != 0 {
+		t.Errorf("registered token should not be tagged")
+	}
+}
+
+func TestBlockScanner_IsTokenRegistered(t *testing.T) {
+	wm := NewWalletManager()
+	bs := wm.Blockscanner
+
+	if !bs.IsTokenRegistered("ANY.COIN", nil) {
+		t.Errorf("all tokens should be registered without filter")
+	}
+
+	wm.Config.TokenDenyList = []string{"SPAM.COIN"}
+	if bs.IsTokenRegistered("SPAM.COIN", nil) || !bs.IsTokenRegistered("NSG.CNY", nil) {
+		t.Errorf("deny list not applied")
+	}
+
+	wm.Config.TokenDenyList = nil
+	wm.Config.TokenFilterByScanTarget = true
+	scanTargetFuncV2 := func(target openwallet.ScanTargetParam) openwallet.ScanTargetResult {
+		return openwallet.ScanTargetResult{Exist: target.ScanTarget == "NSG.CNY"}
+	}
+	if bs.IsTokenRegistered("SPAM.COIN", scanTargetFuncV2) || !bs.IsTokenRegistered("NSG.CNY", scanTargetFuncV2) {
+		t.Errorf("scan target filter not applied")
+	}
+
+	bs.TokenFilterFunc = func(currency string) bool { return currency == "SPAM.COIN" }
+	if !bs.IsTokenRegistered("SPAM.COIN", scanTargetFuncV2) {
+		t.Errorf("TokenFilterFunc should take precedence")
+	}
+}
+
+func TestBlockScanner_ExtractTransaction_DepositTag(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.DepositTagMode = true
+	wm.Config.depositMemoRegexp, _ = parseDepositMemoFormat(`^uid:([0-9]+)$`)
+	sender := "NKkZ1JhkLZ8PXnF5TLmRoAgXs4gGLXzvbW"
+	shared := "NEXaVgHXGdrAW5i1wyyxBJrVJhH3HdmD7f"
+	trx := &rpc.Transaction{
+		ID:          "7d1e2f3a",
+		Type:        rpc.TxType_NSG,
+		SenderID:    sender,
+		RecipientId: shared,
+		Amount:      100000000,
+		Fee:         10000000,
+		Message:     "uid:10086",
+	}
+	scanTarget := func(target openwallet.ScanTarget) (string, bool) {
+		if target.Address != shared {
+			return "", false
+		}
+		if target.BalanceModelType == openwallet.BalanceModelTypeAccount {
+			return "user-" + target.Alias, target.Alias == "10086"
+		}
+		return "exchange", true
+	}
+
+	//标签匹配的充值归入标签对应的账户
+	result := wm.Blockscanner.ExtractTransaction(100, "blockhash", 0, trx, scanTarget)
+	array := result.extractData["user-10086"]
+	if len(array) != 1 || len(result.extractData) != 1 {
+		t.Fatalf("extract data = %v, want user-10086 only", result.extractData)
+	}
+	if ext := array[0].Transaction.GetExtParam(); ext.Get("depositTag").String() != "10086" || ext.Get("depositUnmatched").Bool() {
+		t.Errorf("ext param = %s", ext.Raw)
+	}
+
+	//未匹配的充值归入地址所属账户
+	trx.Message = "uid:1"
+	result = wm.Blockscanner.ExtractTransaction(100, "blockhash", 0, trx, scanTarget)
+	array = result.extractData["exchange"]
+	if len(array) != 1 || !array[0].Transaction.GetExtParam().Get("depositUnmatched").Bool() {
+		t.Errorf("unmatched deposit should be credited to exchange and tagged")
+	}
+
+	//配置了兜底sourceKey时归入兜底
+	wm.Config.DepositFallbackKey = "unmatched"
+	trx.Message = "hello"
+	result = wm.Blockscanner.ExtractTransaction(100, "blockhash", 0, trx, scanTarget)
+	if len(result.extractData["unmatched"]) != 1 || len(result.extractData["exchange"]) != 0 {
+		t.Errorf("unmatched deposit should be credited to fallback, extract data = %v", result.extractData)
+	}
+}
+
+func TestDepositTag(t *testing.T) {
+	format, _ := parseDepositMemoFormat(`^uid:([0-9]+)$`)
+	if tag := depositTag(" uid:42 ", format); tag != "42" {
+		t.Errorf("depositTag() = %s, want 42", tag)
+	}
+	if tag := depositTag("uid:abc", format); tag != "" {
+		t.Errorf("depositTag() = %s, want empty", tag)
+	}
+	if tag := depositTag(" 42 ", nil); tag != "42" {
+		t.Errorf("depositTag() without format = %s, want 42", tag)
+	}
+	if _, err := parseDepositMemoFormat("("); err == nil {
+		t.Errorf("parseDepositMemoFormat() want error")
+	}
+}
+
+func TestBlockScanner_ExtractTransaction_AmountEdge(t *testing.T) {
+	wm := NewWalletManager()
+	sender := "NKkZ1JhkLZ8PXnF5TLmRoAgXs4gGLXzvbW"
+	recipient := "NEXaVgHXGdrAW5i1wyyxBJrVJhH3HdmD7f"
+	trx := &rpc.Transaction{
+		ID:          "8e9f0a1b",
+		Type:        rpc.TxType_NSG,
+		SenderID:    sender,
+		RecipientId: recipient,
+		Amount:      math.MaxUint64,
+		Fee:         math.MaxInt64 + 1,
+	}
+	scanTarget := scanTargetOf(map[string]string{recipient: "recipient"})
+
+	//超过int64的数额不溢出
+	result := wm.Blockscanner.ExtractTransaction(100, "blockhash", 0, trx, scanTarget)
+	array := result.extractData["recipient"]
+	if len(array) != 1 {
+		t.Fatalf("extract data count = %d, want 1", len(array))
+	}
+	if tx := array[0].Transaction; tx.Amount != "184467440737.09551615" || tx.Fees != "92233720368.54775808" {
+		t.Errorf("amount = %s, fees = %s", tx.Amount, tx.Fees)
+	}
+
+	//UIA数额不是整数时提取失败
+	trx.Type = rpc.TxType_Asset
+	trx.Asset = &rpc.Asset{UiaTransfer: &rpc.UiaTransfer{Currency: "NSG.CNY", Amount: "1.5", Precision: 6}}
+	result = wm.Blockscanner.ExtractTransaction(100, "blockhash", 0, trx, scanTarget)
+	if result.Success {
+		t.Errorf("ExtractTransaction() of invalid uia amount should fail")
+	}
+}
+
+func TestBlockScanner_ExtractTransactionAndReceiptData(t *testing.T) {
+	sender := "NKkZ1JhkLZ8PXnF5TLmRoAgXs4gGLXzvbW"
+	recipient := "NEXaVgHXGdrAW5i1wyyxBJrVJhH3HdmD7f"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		switch r.URL.Path {
+		case "/api/transactions/get":
+			switch id {
+			case "nsgtx":
+				fmt.Fprintf(w, `{"success":true,"transaction":{"id":"nsgtx","height":"5","type":%d,"senderId":"%s","recipientId":"%s","amount":100000000,"fee":10000000}}`, rpc.TxType_NSG, sender, recipient)
+			case "uiatx":
+				fmt.Fprintf(w, `{"success":true,"transaction":{"id":"uiatx","height":"5","type":%d,"senderId":"%s","recipientId":"%s","fee":10000000}}`, rpc.TxType_Asset, sender, recipient)
+			default:
+				fmt.Fprint(w, `{"success":false,"error":"Transaction not found"}`)
+			}
+		case "/api/uia/transactions/get":
+			if id != "uiatx" {
+				t.Errorf("uia detail of [%s] should not be queried", id)
+			}
+			fmt.Fprintf(w, `{"success":true,"transactions":[{"id":"uiatx","height":"5","type":%d,"senderId":"%s","recipientId":"%s","fee":10000000,"asset":{"uiaTransfer":{"currency":"NSG.CNY","amount":"12345","precision":2}}}]}`, rpc.TxType_Asset, sender, recipient)
+		case "/api/blocks/get":
+			fmt.Fprint(w, `{"success":true,"block":{"id":"block5","height":5,"timestamp":1000}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = rpc.NewClient(server.URL)
+	scanTargetFunc := func(target openwallet.ScanTargetParam) openwallet.ScanTargetResult {
+		switch target.ScanTargetType {
+		case openwallet.ScanTargetTypeAccountAddress:
+			return openwallet.ScanTargetResult{SourceKey: "account", Exist: target.ScanTarget == recipient}
+		case openwallet.ScanTargetTypeContractAddress:
+			return openwallet.ScanTargetResult{SourceKey: target.ScanTarget, Exist: target.ScanTarget == "NSG.CNY"}
+		}
+		return openwallet.ScanTargetResult{}
+	}
+
+	//非UIA交易单也能按txid查询
+	data, _, err := wm.Blockscanner.ExtractTransactionAndReceiptData("nsgtx", scanTargetFunc)
+	if err != nil {
+		t.Fatalf("ExtractTransactionAndReceiptData(nsgtx) error = %v", err)
+	}
+	if array := data["account"]; len(array) != 1 || array[0].Transaction.TxID != "nsgtx" || array[0].Transaction.BlockHeight != 5 {
+		t.Errorf("extract data of nsgtx = %v", data)
+	}
+
+	//UIA转账补充资产明细
+	_, receipts, err := wm.Blockscanner.ExtractTransactionAndReceiptData("uiatx", scanTargetFunc)
+	if err != nil {
+		t.Fatalf("ExtractTransactionAndReceiptData(uiatx) error = %v", err)
+	}
+	receipt := receipts[openwallet.GenContractID(Symbol, "NSG.CNY")]
+	if receipt == nil || len(receipt.Events) != 1 || gjson.Get(receipt.Events[0].Value, "amountShow").String() != "123.45" {
+		t.Errorf("receipts of uiatx = %v", receipts)
+	}
+
+	if _, _, err := wm.Blockscanner.ExtractTransactionAndReceiptData("unknown", scanTargetFunc); err == nil {
+		t.Errorf("ExtractTransactionAndReceiptData(unknown) should return not found error")
+	}
+}
@@ -16,10 +16,15 @@
 package nasgo
 
 import (
+	"fmt"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/nasgo-adapter/addrdec"
+	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/nasgo-adapter/utils"
 	"github.com/blocktree/openwallet/v2/common/file"
 )
 
@@ -33,10 +38,80 @@ const (
 
 # RPC api url
 ServerAPI = ""
-FixFees=0.001
+# fixed fees, override the fee schedule of node. 0 means use the fees from node
+FixFees=0
+# timestamp source of transaction, local or node
+timestampSource = "local"
+# seconds subtracted from the timestamp source
+timestampOffset = 5
+# seconds that transaction is valid after its timestamp
+txValidWindow = 600
+# max seconds of the latest block age, block scanner pause when node is behind
+maxBlockAge = 120
+# blocks prefetched in parallel when catching up, less than 2 to disable
+catchUpWindow = 10
+# confirmations to notify for extracted transactions, empty to disable
+#confirmThresholds = "1,6,33"
+# UIA currencies allowed or denied to extract, comma separated, allow list first
+#tokenAllowList = ""
+#tokenDenyList = ""
+# use the contracts subscribed by scan target as allow list when both lists are empty
+tokenFilterByScanTarget = false
+# action of unregistered token transfers, skip or tag
+tokenFilterAction = "skip"
+# policy to select the sending address: first, largest, smallest or roundrobin
+addressSelectPolicy = "first"
+# credit deposits by memo tag, scan target is looked up by address and alias of tag
+depositTagMode = false
+# regexp of memo tag, the first group is the tag, empty to use the whole memo
+#depositMemoFormat = "^uid:([0-9]+)$"
+# sourceKey of unmatched deposits, empty to credit the account of address
+#depositFallbackKey = ""
+# network params, default by isTestNet, addressPrefix and chainEpoch are required when isTestNet = true
+#addressPrefix = "N"
+#networkMagic = "594fe0f3"
+#networkVersion = "''"
+#chainEpoch = 1520193600
+#networkFees = "0.01"
+#maxMessageLength = 256
 `
 )
 
+//NetworkParams 网络参数，每个钱包管理者持有自己的一份
+type NetworkParams struct {
+	Name          string //网络名称
+	AddressPrefix string //地址前缀
+	Magic         string //peer接口的magic头，即网络的nethash
+	Version       string //peer接口的version头
+	Epoch         int64  //链的起始时间，unix秒
+	Fees          string //默认手续费，节点手续费不可用时使用
+	MaxMessage    int    //交易备注的最大字节数
+}
+
+var (
+	//MainNetParams 主网参数
+	MainNetParams = NetworkParams{
+		Name:          "mainnet",
+		AddressPrefix: addrdec.DefaultPrefix,
+		Magic:         rpc.DefaultMagic,
+		Version:       rpc.DefaultVersion,
+		Epoch:         utils.MainnetEpoch,
+		Fees:          "0.01",
+		MaxMessage:    rpc.MaxMessageLength,
+	}
+	//TestNetParams 测试网参数，各测试网的地址前缀和起始时间不同，需在配置中指定addressPrefix和chainEpoch
+	//magic为空时采用节点的nethash，没有默认手续费，节点手续费不可用时需配置fixFees或networkFees
+	TestNetParams = NetworkParams{
+		Name:          "testnet",
+		AddressPrefix: "",
+		Magic:         "",
+		Version:       rpc.DefaultVersion,
+		Epoch:         0,
+		Fees:          "",
+		MaxMessage:    rpc.MaxMessageLength,
+	}
+)
+
 type WalletConfig struct {
 
 	//币种
@@ -61,10 +136,41 @@ type WalletConfig struct {
 	MaxTxInputs int
 	//数据目录
 	DataDir string
-	//固定手续费
+	//固定手续费，大于0时覆盖节点的手续费
 	FixFees string
 	//重试次数
 	RpcRetry int64
+	//交易单有效期，秒，超过后未打包视为丢弃
+	TxValidWindow int64
+	//交易单时间戳来源，local或node
+	TimestampSource string
+	//交易单时间戳相对于时间源的偏移，秒
+	TimestampOffset int64
+	//最新区块允许的最大延迟，秒，超过后暂停扫块
+	MaxBlockAge int64
+	//追块模式并行预取的区块数，小于2时不启用
+	CatchUpWindow int
+	//确认数通知阈值，升序，为空时不追踪确认数
+	ConfirmThresholds []uint64
+	//允许的UIA资产，不为空时只提取列表中的资产转账
+	TokenAllowList []string
+	//禁止的UIA资产，允许列表为空时生效
+	TokenDenyList []string
+	//资产列表都为空时，是否以scanTargetFuncV2订阅的合约作为已登记资产
+	TokenFilterByScanTarget bool
+	//未登记资产转账的处理方式，skip或tag
+	TokenFilterAction string
+	//发送地址选择策略，first、largest、smallest或roundrobin
+	AddressSelectPolicy string
+	//按备注标签区分充值账户，以(地址, 标签)查找ScanTarget
+	DepositTagMode bool
+	//备注标签格式，正则表达式，有分组时取第一个分组，为空时取整个备注
+	DepositMemoFormat string
+	depositMemoRegexp *regexp.Regexp
+	//未匹配标签的充值归入的sourceKey，为空时归入地址所属账户
+	DepositFallbackKey string
+	//网络参数
+	Network NetworkParams
 }
 
 func NewConfig(symbol string) *WalletConfig {
@@ -88,6 +194,14 @@ func NewConfig(symbol string) *WalletConfig {
 	c.MaxTxInputs = 50
 	c.FixFees = "0"
 	c.RpcRetry = 1
+	c.TxValidWindow = 600
+	c.TimestampSource = TimestampSourceLocal
+	c.TimestampOffset = 5
+	c.MaxBlockAge = 120
+	c.TokenFilterAction = TokenFilterActionSkip
+	c.AddressSelectPolicy = AddressSelectFirst
+	c.CatchUpWindow = 10
+	c.SetNetworkParams(MainNetParams)
 
 	//创建目录
 	//file.MkdirAll(c.dbPath)
@@ -95,6 +209,27 @@ func NewConfig(symbol string) *WalletConfig {
 	return &c
 }
 
+//SetNetworkParams 设置网络参数
+func (wc *WalletConfig) SetNetworkParams(params NetworkParams) {
+	wc.Network = params
+}
+
+//check 检查网络参数是否完整
+func (params NetworkParams) check() error {
+	if len(params.AddressPrefix) == 0 {
+		return fmt.Errorf("addressPrefix of %s is not configured", params.Name)
+	}
+	if params.Epoch <= 0 {
+		return fmt.Errorf("chainEpoch of %s is not configured", params.Name)
+	}
+	return nil
+}
+
+//GetEpochTime 当前链上时间
+func (wc *WalletConfig) GetEpochTime() int64 {
+	return utils.GetEpochTimeSince(wc.Network.Epoch)
+}
+
 //创建文件夹
 func (wc *WalletConfig) makeDataDir() {
 
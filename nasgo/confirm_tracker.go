@@ -0,0 +1,228 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//TxConfirmation 交易单确认数通知
+type TxConfirmation struct {
+	WxID          string
+	TxID          string
+	SourceKey     string //订阅的账户
+	BlockHeight   uint64
+	BlockHash     string
+	Confirmations uint64                  //当前确认数，交易所在区块为1
+	Threshold     uint64                  //本次到达的确认数阈值
+	Transaction   *openwallet.Transaction //Confirm为当前确认数的交易记录副本
+}
+
+//TxConfirmNotificationObject 确认数被通知对象
+//扫描器的观测者实现了该接口，也会收到通知
+type TxConfirmNotificationObject interface {
+
+	//TxConfirmNotify 交易单到达确认数阈值通知
+	TxConfirmNotify(confirm *TxConfirmation) error
+}
+
+type confirmingTx struct {
+	sourceKey string
+	tx        *openwallet.Transaction
+	next      int //下一个待通知的阈值下标
+}
+
+//ConfirmTracker 按本地区块头追踪已提取交易单的确认数，到达配置的阈值时通知
+//追踪数据只保存在内存，重启后未到达最后阈值的交易单不再通知
+type ConfirmTracker struct {
+	wm        *WalletManager
+	mu        sync.Mutex
+	txs       map[string]*confirmingTx
+	observers map[TxConfirmNotificationObject]bool
+}
+
+//NewConfirmTracker 确认数追踪器
+func NewConfirmTracker(wm *WalletManager) *ConfirmTracker {
+	tracker := ConfirmTracker{}
+	tracker.wm = wm
+	tracker.txs = make(map[string]*confirmingTx)
+	tracker.observers = make(map[TxConfirmNotificationObject]bool)
+	return &tracker
+}
+
+//AddObserver 添加观测者
+func (tracker *ConfirmTracker) AddObserver(obj TxConfirmNotificationObject) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if obj == nil {
+		return
+	}
+	tracker.observers[obj] = true
+}
+
+//RemoveObserver 移除观测者
+func (tracker *ConfirmTracker) RemoveObserver(obj TxConfirmNotificationObject) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	delete(tracker.observers, obj)
+}
+
+//Track 追踪提取的交易单，未配置阈值时忽略，同一账户的同一交易单只追踪一次
+func (tracker *ConfirmTracker) Track(sourceKey string, data *openwallet.TxExtractData) {
+
+	if len(tracker.wm.Config.ConfirmThresholds) == 0 || data == nil || data.Transaction == nil {
+		return
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	key := sourceKey + "_" + data.Transaction.WxID
+	if _, exist := tracker.txs[key]; exist {
+		return
+	}
+
+	tracker.txs[key] = &confirmingTx{
+		sourceKey: sourceKey,
+		tx:        data.Transaction,
+	}
+}
+
+//Count 正在追踪的交易单数量
+func (tracker *ConfirmTracker) Count() int {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return len(tracker.txs)
+}
+
+//Update 区块头更新到height后，通知到达阈值的交易单，到达最后阈值的交易单结束追踪
+func (tracker *ConfirmTracker) Update(height uint64) {
+
+	thresholds := tracker.wm.Config.ConfirmThresholds
+	confirms := make([]*TxConfirmation, 0)
+
+	tracker.mu.Lock()
+	for key, item := range tracker.txs {
+		if height < item.tx.BlockHeight {
+			continue
+		}
+		confirmations := height - item.tx.BlockHeight + 1
+
+		//追块时一次可能跨过多个阈值，逐个通知
+		for item.next < len(thresholds) && thresholds[item.next] <= confirmations {
+			tx := *item.tx
+			tx.Confirm = int64(confirmations)
+			confirms = append(confirms, &TxConfirmation{
+				WxID:          tx.WxID,
+				TxID:          tx.TxID,
+				SourceKey:     item.sourceKey,
+				BlockHeight:   tx.BlockHeight,
+				BlockHash:     tx.BlockHash,
+				Confirmations: confirmations,
+				Threshold:     thresholds[item.next],
+				Transaction:   &tx,
+			})
+			item.next++
+		}
+
+		if item.next >= len(thresholds) {
+			delete(tracker.txs, key)
+		}
+	}
+	tracker.mu.Unlock()
+
+	//按高度和阈值顺序通知
+	sort.SliceStable(confirms, func(i, j int) bool {
+		if confirms[i].BlockHeight != confirms[j].BlockHeight {
+			return confirms[i].BlockHeight < confirms[j].BlockHeight
+		}
+		return confirms[i].Threshold < confirms[j].Threshold
+	})
+
+	for _, confirm := range confirms {
+		tracker.notify(confirm)
+	}
+}
+
+//Rollback 区块回滚到height，高于height的交易单结束追踪，重扫时会重新追踪
+func (tracker *ConfirmTracker) Rollback(height uint64) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	for key, item := range tracker.txs {
+		if item.tx.BlockHeight > height {
+			delete(tracker.txs, key)
+		}
+	}
+}
+
+//notify 通知观测者
+func (tracker *ConfirmTracker) notify(confirm *TxConfirmation) {
+
+	observers := make([]TxConfirmNotificationObject, 0)
+
+	tracker.mu.Lock()
+	for o := range tracker.observers {
+		observers = append(observers, o)
+	}
+	tracker.mu.Unlock()
+
+	tracker.wm.Blockscanner.Mu.RLock()
+	for o := range tracker.wm.Blockscanner.Observers {
+		if obj, ok := o.(TxConfirmNotificationObject); ok {
+			observers = append(observers, obj)
+		}
+	}
+	tracker.wm.Blockscanner.Mu.RUnlock()
+
+	for _, o := range observers {
+		err := o.TxConfirmNotify(confirm)
+		if err != nil {
+			tracker.wm.Log.Std.Error("TxConfirmNotify unexpected error: %v", err)
+		}
+	}
+}
+
+//parseConfirmThresholds 解析逗号分隔的确认数阈值，升序去重
+func parseConfirmThresholds(value string) ([]uint64, error) {
+
+	thresholds := make([]uint64, 0)
+	exist := make(map[uint64]bool)
+
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			continue
+		}
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid confirm threshold: %s", s)
+		}
+		if !exist[n] {
+			exist[n] = true
+			thresholds = append(thresholds, n)
+		}
+	}
+
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i] < thresholds[j] })
+
+	return thresholds, nil
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+type confirmRecorder struct {
+	confirms []*TxConfirmation
+}
+
+func (r *confirmRecorder) TxConfirmNotify(confirm *TxConfirmation) error {
+	r.confirms = append(r.confirms, confirm)
+	return nil
+}
+
+func TestParseConfirmThresholds(t *testing.T) {
+	got, err := parseConfirmThresholds(" 33, 1,6,6 ")
+	if err != nil {
+		t.Errorf("parseConfirmThresholds() error = %v", err)
+		return
+	}
+	if want := []uint64{1, 6, 33}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseConfirmThresholds() = %v, want %v", got, want)
+	}
+
+	for _, value := range []string{"0", "1,a", "-1"} {
+		if _, err := parseConfirmThresholds(value); err == nil {
+			t.Errorf("parseConfirmThresholds(%s) want error", value)
+		}
+	}
+}
+
+func TestConfirmTracker_Update(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.ConfirmThresholds = []uint64{1, 6, 33}
+	tracker := wm.Blockscanner.ConfirmTracker
+	recorder := &confirmRecorder{}
+	tracker.AddObserver(recorder)
+
+	data := &openwallet.TxExtractData{
+		Transaction: &openwallet.Transaction{TxID: "tx1", WxID: "wx1", BlockHeight: 100},
+	}
+	tracker.Track("account", data)
+	tracker.Track("account", data)
+	if tracker.Count() != 1 {
+		t.Errorf("Count() = %d, want 1", tracker.Count())
+	}
+
+	tracker.Update(100)
+	tracker.Update(104)
+	//追块跨过两个阈值
+	tracker.Update(140)
+
+	if len(recorder.confirms) != 3 {
+		t.Fatalf("notified %d times, want 3", len(recorder.confirms))
+	}
+	for i, want := range []uint64{1, 6, 33} {
+		confirm := recorder.confirms[i]
+		if confirm.Threshold != want || confirm.WxID != "wx1" || confirm.SourceKey != "account" {
+			t.Errorf("confirm[%d] = %+v, want threshold %d", i, confirm, want)
+		}
+	}
+	if got := recorder.confirms[2].Transaction.Confirm; got != 41 {
+		t.Errorf("Transaction.Confirm = %d, want 41", got)
+	}
+	if tracker.Count() != 0 {
+		t.Errorf("Count() = %d, want 0", tracker.Count())
+	}
+}
+
+func TestConfirmTracker_Rollback(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.ConfirmThresholds = []uint64{6}
+	tracker := wm.Blockscanner.ConfirmTracker
+
+	tracker.Track("account", &openwallet.TxExtractData{
+		Transaction: &openwallet.Transaction{TxID: "tx1", WxID: "wx1", BlockHeight: 100},
+	})
+	tracker.Track("account", &openwallet.TxExtractData{
+		Transaction: &openwallet.Transaction{TxID: "tx2", WxID: "wx2", BlockHeight: 102},
+	})
+
+	tracker.Rollback(101)
+	if tracker.Count() != 1 {
+		t.Errorf("Count() = %d, want 1", tracker.Count())
+	}
+}
@@ -16,27 +16,107 @@
 package nasgo
 
 import (
+	"fmt"
+	"sync"
+
+	"github.com/blocktree/nasgo-adapter/utils"
 	"github.com/blocktree/openwallet/v2/openwallet"
-	"github.com/shopspring/decimal"
 )
 
 type ContractDecoder struct {
 	openwallet.SmartContractDecoderBase
-	wm *WalletManager
+	wm        *WalletManager
+	assetsMu  sync.RWMutex
+	assetsMap map[string]*TokenMetadata //资产信息缓存
+}
+
+//TokenMetadata UIA资产信息
+type TokenMetadata struct {
+	Name      string //资产全名，发行商.币种
+	Precision uint64 //精度
+	MaxSupply string //最大发行量
+	Issued    string //已发行量
+	Issuer    string //发行商地址
 }
 
 //NewContractDecoder 智能合约解析器
 func NewContractDecoder(wm *WalletManager) *ContractDecoder {
 	decoder := ContractDecoder{}
 	decoder.wm = wm
+	decoder.assetsMap = make(map[string]*TokenMetadata)
 	return &decoder
 }
 
+//GetTokenMetadata 查询UIA资产信息，结果会缓存
+func (decoder *ContractDecoder) GetTokenMetadata(name string) (*TokenMetadata, error) {
+
+	decoder.assetsMu.RLock()
+	metadata, ok := decoder.assetsMap[name]
+	decoder.assetsMu.RUnlock()
+	if ok {
+		return metadata, nil
+	}
+
+	asset, err := decoder.wm.WalletClient.Uia.GetAsset(name)
+	if err != nil {
+		return nil, err
+	}
+
+	precision := int32(asset.Precision)
+	maxSupply, err := utils.ParseRawAmount(asset.Maximum)
+	if err == nil {
+		maxSupply, err = utils.FromRawAmount(maxSupply, precision)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid max supply [%s] of token [%s], err: %v", asset.Maximum, name, err)
+	}
+	issued, err := utils.ParseRawAmount(asset.Quantity)
+	if err == nil {
+		issued, err = utils.FromRawAmount(issued, precision)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid issued amount [%s] of token [%s], err: %v", asset.Quantity, name, err)
+	}
+
+	metadata = &TokenMetadata{
+		Name:      asset.Name,
+		Precision: uint64(asset.Precision),
+		MaxSupply: maxSupply.String(),
+		Issued:    issued.String(),
+		Issuer:    asset.IssuerID,
+	}
+
+	decoder.assetsMu.Lock()
+	decoder.assetsMap[name] = metadata
+	decoder.assetsMu.Unlock()
+
+	return metadata, nil
+}
+
+//CheckContract 检查合约配置的精度是否与链上一致
+func (decoder *ContractDecoder) CheckContract(contract openwallet.SmartContract) error {
+
+	metadata, err := decoder.GetTokenMetadata(contract.Address)
+	if err != nil {
+		return openwallet.Errorf(openwallet.ErrSystemException, "can not get token [%s] info, err: %v", contract.Address, err)
+	}
+
+	if metadata.Precision != contract.Decimals {
+		return openwallet.Errorf(openwallet.ErrSystemException, "token [%s] decimals mismatch, config: %d, chain: %d", contract.Address, contract.Decimals, metadata.Precision)
+	}
+
+	return nil
+}
+
 // GetTokenBalanceByAddress return the balance by address, queried by rpc
 func (decoder *ContractDecoder) GetTokenBalanceByAddress(contract openwallet.SmartContract, address ...string) ([]*openwallet.TokenBalance, error) {
 
 	tokenBalanceList := make([]*openwallet.TokenBalance, 0)
 
+	if err := decoder.CheckContract(contract); err != nil {
+		return nil, err
+	}
+
 	for _, addr := range address {
 
 		balance, err := decoder.wm.WalletClient.Wallet.GetAssetsBalance(addr, contract.Address)
@@ -59,9 +139,16 @@ func (decoder *ContractDecoder) GetTokenBalanceByAddress(contract openwallet.Sma
 			tokenBalanceList = append(tokenBalanceList, tokenBalance)
 
 		} else {
-			value, _ := decimal.NewFromString(balance.Balance)
-			value = value.Shift(-int32(contract.Decimals))
-			// value = value.Shift(-int32(balance.Precision))
+			if uint64(balance.Precision) != contract.Decimals {
+				return nil, fmt.Errorf("token [%s] decimals mismatch, config: %d, chain: %d", contract.Address, contract.Decimals, balance.Precision)
+			}
+			value, err := balance.RawBalance()
+			if err == nil {
+				value, err = utils.FromRawAmount(value, int32(contract.Decimals))
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid balance [%s] of token [%s], err: %v", balance.Balance, contract.Address, err)
+			}
 
 			tokenBalance := &openwallet.TokenBalance{
 				Contract: &contract,
@@ -82,3 +169,53 @@ func (decoder *ContractDecoder) GetTokenBalanceByAddress(contract openwallet.Sma
 	return tokenBalanceList, nil
 
 }
+
+// GetTokenBalanceListByAddress return all the uia balances held by the addresses, including
+// the tokens which have not been registered as contract
+func (decoder *ContractDecoder) GetTokenBalanceListByAddress(address ...string) ([]*openwallet.TokenBalance, error) {
+
+	tokenBalanceList := make([]*openwallet.TokenBalance, 0)
+
+	for _, addr := range address {
+
+		balances, err := decoder.wm.WalletClient.Wallet.GetAssetsBalanceList(addr)
+		if err != nil {
+			decoder.wm.Log.Errorf("get account[%v] token balance list failed, err: %v", addr, err)
+			return nil, err
+		}
+
+		for _, balance := range balances {
+			value, err := balance.RawBalance()
+			if err == nil {
+				value, err = utils.FromRawAmount(value, int32(balance.Precision))
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid balance [%s] of token [%s], err: %v", balance.Balance, balance.Currency, err)
+			}
+
+			contract := &openwallet.SmartContract{
+				ContractID: openwallet.GenContractID(decoder.wm.Symbol(), balance.Currency),
+				Symbol:     decoder.wm.Symbol(),
+				Address:    balance.Currency,
+				Token:      balance.Currency,
+				Name:       balance.Currency,
+				Decimals:   uint64(balance.Precision),
+			}
+
+			tokenBalance := &openwallet.TokenBalance{
+				Contract: contract,
+				Balance: &openwallet.Balance{
+					Address:          addr,
+					Symbol:           contract.Symbol,
+					Balance:          value.String(),
+					ConfirmBalance:   value.String(),
+					UnconfirmBalance: "0",
+				},
+			}
+
+			tokenBalanceList = append(tokenBalanceList, tokenBalance)
+		}
+	}
+
+	return tokenBalanceList, nil
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//parseDepositMemoFormat 解析备注标签格式
+func parseDepositMemoFormat(format string) (*regexp.Regexp, error) {
+	if len(format) == 0 {
+		return nil, nil
+	}
+	re, err := regexp.Compile(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid depositMemoFormat: %v", err)
+	}
+	return re, nil
+}
+
+//depositTag 从备注中提取充值标签，格式有分组时取第一个分组，格式为空时取整个备注，不匹配时返回空
+func depositTag(message string, format *regexp.Regexp) string {
+	message = strings.TrimSpace(message)
+	if len(message) == 0 || format == nil {
+		return message
+	}
+	match := format.FindStringSubmatch(message)
+	if match == nil {
+		return ""
+	}
+	if len(match) > 1 {
+		return strings.TrimSpace(match[1])
+	}
+	return match[0]
+}
+
+//routeDeposit 按(接收地址, 备注标签)查找充值账户，ScanTarget的Alias为标签，余额模型为账户模型
+//标签未匹配时，订阅地址的充值归入DepositFallbackKey，未配置时仍归入地址所属账户
+func (bs *BlockScanner) routeDeposit(to, message, accountID string, ok bool, scanTargetFunc openwallet.BlockScanTargetFunc, result *ExtractResult) (string, bool) {
+
+	tag := depositTag(message, bs.wm.Config.depositMemoRegexp)
+	if len(tag) > 0 {
+		sourceKey, exist := scanTargetFunc(openwallet.ScanTarget{
+			Address:          to,
+			Alias:            tag,
+			Symbol:           bs.wm.Symbol(),
+			BalanceModelType: openwallet.BalanceModelTypeAccount,
+		})
+		if exist {
+			result.depositTag = tag
+			return sourceKey, true
+		}
+	}
+
+	if !ok {
+		return accountID, ok
+	}
+
+	result.depositTag = tag
+	result.depositUnmatched = true
+	if fallback := bs.wm.Config.DepositFallbackKey; len(fallback) > 0 {
+		bs.wm.Log.Std.Notice("deposit [%s] to [%s] with memo [%s] is unmatched, credit to fallback: %s", result.TxID, to, message, fallback)
+		return fallback, true
+	}
+
+	return accountID, ok
+}
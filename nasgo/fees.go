@@ -0,0 +1,104 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/nasgo-adapter/utils"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	feeCacheExpired = 10 * time.Minute //手续费缓存有效期
+)
+
+//feeCache 节点手续费缓存
+type feeCache struct {
+	mu        sync.Mutex
+	schedule  *rpc.FeeSchedule
+	updatedAt time.Time
+}
+
+//GetFeeSchedule 获取节点的手续费表，缓存feeCacheExpired
+func (wm *WalletManager) GetFeeSchedule() (*rpc.FeeSchedule, error) {
+
+	wm.fees.mu.Lock()
+	defer wm.fees.mu.Unlock()
+
+	if wm.fees.schedule != nil && time.Since(wm.fees.updatedAt) < feeCacheExpired {
+		return wm.fees.schedule, nil
+	}
+
+	schedule, err := wm.WalletClient.Block.GetFeeSchedule()
+	if err != nil {
+		//节点不可用时，沿用旧的手续费表
+		if wm.fees.schedule != nil {
+			wm.Log.Std.Warning("get fee schedule failed, use the cached one; unexpected error: %v", err)
+			return wm.fees.schedule, nil
+		}
+		return nil, err
+	}
+
+	wm.fees.schedule = schedule
+	wm.fees.updatedAt = time.Now()
+
+	return schedule, nil
+}
+
+//GetTransactionFee 获取交易类型的手续费，配置了FixFees则优先使用，FixFees格式错误时返回错误
+func (wm *WalletManager) GetTransactionFee(txType uint32) (decimal.Decimal, error) {
+
+	if len(wm.Config.FixFees) > 0 {
+		fixFees, err := utils.ParseAmount(wm.Config.FixFees, wm.Decimal())
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("invalid fixFees: %v", err)
+		}
+		if fixFees.GreaterThan(decimal.Zero) {
+			return fixFees, nil
+		}
+	}
+
+	schedule, err := wm.GetFeeSchedule()
+	if err != nil {
+		//节点不可用时，转账类交易使用网络默认手续费
+		defaultFees, parseErr := utils.ParseAmount(wm.Config.Network.Fees, wm.Decimal())
+		if parseErr == nil && defaultFees.GreaterThan(decimal.Zero) && (txType == rpc.TxType_NSG || txType == rpc.TxType_Asset) {
+			wm.Log.Std.Warning("get fee schedule failed, use the default fees of %s: %s; unexpected error: %v", wm.Config.Network.Name, defaultFees.String(), err)
+			return defaultFees, nil
+		}
+		return decimal.Zero, err
+	}
+
+	var fee uint64
+	switch txType {
+	case rpc.TxType_NSG:
+		fee = schedule.Transfer
+	case rpc.TxType_Asset:
+		fee = schedule.Asset
+	case rpc.TxType_Vote:
+		fee = schedule.Vote
+	case rpc.TxType_Delegate:
+		fee = schedule.Delegate
+	default:
+		return decimal.Zero, fmt.Errorf("fee of transaction type [%d] is not supported", txType)
+	}
+
+	return utils.RawToDecimal(fee, wm.Decimal()), nil
+}
@@ -0,0 +1,250 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/blocktree/nasgo-adapter/utils"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//getFeesSupportAccount 手续费支持账户及其地址
+func (decoder *TransactionDecoder) getFeesSupportAccount(wrapper openwallet.WalletDAI, feesSupport *openwallet.FeesSupportAccount) (*openwallet.AssetsAccount, []*openwallet.Address, error) {
+
+	account, err := wrapper.GetAssetsAccountInfo(feesSupport.AccountID)
+	if err != nil {
+		return nil, nil, openwallet.Errorf(openwallet.ErrAccountNotFound, "can not find fees support account")
+	}
+
+	addresses, err := decoder.getAccountAddresses(wrapper, account.AccountID)
+	if err != nil {
+		return nil, nil, openwallet.Errorf(openwallet.ErrAccountNotAddress, "fees support account have not addresses")
+	}
+
+	return account, addresses, nil
+}
+
+//feesSupportSource 手续费支持地址及其可用主币余额，链上整数
+type feesSupportSource struct {
+	address *openwallet.Address
+	balance decimal.Decimal
+}
+
+//feesSupportPool 手续费支持账户的地址池，补充交易轮流从余额足够的地址发出
+type feesSupportPool struct {
+	sources []*feesSupportSource
+	next    int
+}
+
+//newFeesSupportPool 查询手续费支持地址的主币余额，查询失败的地址不参与补充
+func (decoder *TransactionDecoder) newFeesSupportPool(addresses []*openwallet.Address) *feesSupportPool {
+
+	pool := &feesSupportPool{sources: make([]*feesSupportSource, 0, len(addresses))}
+	for _, addr := range addresses {
+		b, err := decoder.wm.WalletClient.Wallet.GetBalance(addr.Address)
+		if err != nil {
+			decoder.wm.Log.Notice("get balance of fees support address [%s] failed: %v", addr.Address, err)
+			continue
+		}
+		pool.sources = append(pool.sources, &feesSupportSource{
+			address: addr,
+			balance: utils.RawToDecimal(b, 0),
+		})
+	}
+
+	return pool
+}
+
+//allocate 从上次选中地址的下一个开始，选择能支付supportAmount和手续费的地址并扣减其余额，没有足够余额的地址时返回nil
+func (pool *feesSupportPool) allocate(supportAmount, fees decimal.Decimal) *feesSupportSource {
+
+	cost := supportAmount.Add(fees)
+	for i := range pool.sources {
+		index := (pool.next + i) % len(pool.sources)
+		source := pool.sources[index]
+		if source.balance.GreaterThanOrEqual(cost) {
+			source.balance = source.balance.Sub(cost)
+			pool.next = index + 1
+			return source
+		}
+	}
+
+	return nil
+}
+
+//allocateFeesSupport 从地址池选择手续费支持地址，余额均不足时返回错误
+func allocateFeesSupport(pool *feesSupportPool, account *openwallet.AssetsAccount, supportAmount, fees decimal.Decimal) (*feesSupportSource, error) {
+	source := pool.allocate(supportAmount, fees)
+	if source == nil {
+		return nil, openwallet.Errorf(openwallet.ErrInsufficientFees, "the balance of fees support account [%s] is not enough, need: %s", account.AccountID, supportAmount.Add(fees).String())
+	}
+	return source, nil
+}
+
+//feesSupportAmount 手续费支持数量，链上整数，优先采用固定数量，其次按手续费倍率，默认为手续费
+//FixSupportAmount为带小数位的主币数量，fees为链上整数
+func feesSupportAmount(feesSupport *openwallet.FeesSupportAccount, fees decimal.Decimal, decimals int32) (decimal.Decimal, error) {
+
+	if len(feesSupport.FixSupportAmount) > 0 {
+		fixSupportAmount, err := utils.ParseAmount(feesSupport.FixSupportAmount, decimals)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("fixSupportAmount: %v", err)
+		}
+		if fixSupportAmount.GreaterThan(decimal.Zero) {
+			return utils.ToRawAmount(fixSupportAmount, decimals)
+		}
+	}
+
+	if len(feesSupport.FeesSupportScale) > 0 {
+		feesSupportScale, err := decimal.NewFromString(feesSupport.FeesSupportScale)
+		if err != nil || feesSupportScale.Sign() < 0 {
+			return decimal.Zero, fmt.Errorf("feesSupportScale: invalid scale %q", feesSupport.FeesSupportScale)
+		}
+		if feesSupportScale.GreaterThan(decimal.Zero) {
+			supportAmount, err := utils.ToRawAmount(feesSupportScale.Mul(fees), 0)
+			if err != nil {
+				return decimal.Zero, fmt.Errorf("feesSupportScale: %v", err)
+			}
+			return supportAmount, nil
+		}
+	}
+
+	return fees, nil
+}
+
+//createFeesSupportRawTransaction 把rawTx构建为手续费支持账户向address补充主币的交易单，fees和supportAmount为链上整数
+func (decoder *TransactionDecoder) createFeesSupportRawTransaction(
+	wrapper openwallet.WalletDAI,
+	rawTx *openwallet.RawTransaction,
+	account *openwallet.AssetsAccount,
+	from *openwallet.Address,
+	address string,
+	supportAmount decimal.Decimal,
+	fees decimal.Decimal,
+) error {
+
+	sendAmount, err := utils.FormatAmount(supportAmount, decoder.wm.Decimal())
+	if err != nil {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "support amount: %v", err)
+	}
+	feesShow, err := utils.FromRawAmount(fees, decoder.wm.Decimal())
+	if err != nil {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "fees: %v", err)
+	}
+
+	decoder.wm.Log.Debugf("create transaction for fees support account")
+	decoder.wm.Log.Debugf("fees account: %s", account.AccountID)
+	decoder.wm.Log.Debugf("mini support amount: %s", fees.String())
+	decoder.wm.Log.Debugf("allow support amount: %s", supportAmount.String())
+	decoder.wm.Log.Debugf("support address: %s", address)
+
+	rawTx.Coin = openwallet.Coin{
+		Symbol:     decoder.wm.Symbol(),
+		IsContract: false,
+	}
+	rawTx.Account = account
+	rawTx.To = map[string]string{
+		address: sendAmount,
+	}
+	rawTx.Required = 1
+	rawTx.FeeRate = feesShow.StringFixed(decoder.wm.Decimal())
+	rawTx.Fees = rawTx.FeeRate
+
+	return decoder.createNSGRawTransaction(wrapper, rawTx, from, address, supportAmount, sendAmount, feesShow)
+}
+
+//FeesSupportError 代币转账地址的主币不足支付手续费，错误码为ErrInsufficientFees
+//SupportTx为手续费支持账户向Address补充主币的交易单，原交易单不变，补充交易确认后重新创建代币转账
+type FeesSupportError struct {
+	Err       *openwallet.Error          //ErrInsufficientFees错误
+	Address   string                     //主币不足的转账地址
+	SupportTx *openwallet.RawTransaction //手续费补充交易单
+}
+
+func (e *FeesSupportError) Error() string {
+	return e.Err.Error()
+}
+
+//Code 错误码ErrInsufficientFees
+func (e *FeesSupportError) Code() uint64 {
+	return e.Err.Code()
+}
+
+//createFeesSupportForTransfer 代币转账地址主币不足支付手续费时，另外创建手续费支持账户的补充交易单
+//rawTx不修改，补充交易单通过FeesSupportError返回，扩展参数feesSupportFor记录原转账地址和合约
+func (decoder *TransactionDecoder) createFeesSupportForTransfer(
+	wrapper openwallet.WalletDAI,
+	rawTx *openwallet.RawTransaction,
+	feesSupport *openwallet.FeesSupportAccount,
+	address string,
+	fees decimal.Decimal,
+) error {
+
+	account, feesAddresses, err := decoder.getFeesSupportAccount(wrapper, feesSupport)
+	if err != nil {
+		return err
+	}
+
+	feesShow := fees
+	fees, err = utils.ToRawAmount(fees, decoder.wm.Decimal())
+	if err != nil {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "fees: %v", err)
+	}
+	supportAmount, err := feesSupportAmount(feesSupport, fees, decoder.wm.Decimal())
+	if err != nil {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "fees support amount: %v", err)
+	}
+
+	source, err := allocateFeesSupport(decoder.newFeesSupportPool(feesAddresses), account, supportAmount, fees)
+	if err != nil {
+		return err
+	}
+
+	decoder.wm.Log.Std.Notice("address [%s] balance is not enough to pay fees, create fees support transaction from account: %s", address, account.AccountID)
+
+	supportTx := &openwallet.RawTransaction{}
+	supportTx.SetExtParam("feesSupportFor", map[string]interface{}{
+		"address":    address,
+		"accountID":  rawTx.Account.AccountID,
+		"contractID": rawTx.Coin.ContractID,
+		"to":         rawTx.To,
+	})
+	if err := decoder.createFeesSupportRawTransaction(wrapper, supportTx, account, source.address, address, supportAmount, fees); err != nil {
+		return err
+	}
+
+	return &FeesSupportError{
+		Err:       openwallet.Errorf(openwallet.ErrInsufficientFees, "address [%s] balance is not enough to pay fees: %s, submit the fees support transaction first", address, feesShow.StringFixed(decoder.wm.Decimal())),
+		Address:   address,
+		SupportTx: supportTx,
+	}
+}
+
+//getFeesSupportParam 扩展参数feesSupportAccount指定的手续费支持账户
+func getFeesSupportParam(rawTx *openwallet.RawTransaction) *openwallet.FeesSupportAccount {
+	value := rawTx.GetExtParam().Get("feesSupportAccount")
+	if !value.Exists() {
+		return nil
+	}
+	var feesSupport openwallet.FeesSupportAccount
+	if err := json.Unmarshal([]byte(value.Raw), &feesSupport); err != nil || len(feesSupport.AccountID) == 0 {
+		return nil
+	}
+	return &feesSupport
+}
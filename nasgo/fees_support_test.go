@@ -0,0 +1,163 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+func TestFeesSupportPool_Allocate(t *testing.T) {
+	pool := &feesSupportPool{
+		sources: []*feesSupportSource{
+			{address: &openwallet.Address{Address: "A"}, balance: decimal.New(25, 0)},
+			{address: &openwallet.Address{Address: "B"}, balance: decimal.New(5, 0)},
+			{address: &openwallet.Address{Address: "C"}, balance: decimal.New(12, 0)},
+		},
+	}
+	supportAmount, fees := decimal.New(10, 0), decimal.New(2, 0)
+
+	//轮流从余额足够的地址补充，B余额不足被跳过
+	want := []string{"A", "C", "A"}
+	for i, address := range want {
+		source := pool.allocate(supportAmount, fees)
+		if source == nil || source.address.Address != address {
+			t.Fatalf("allocate() #%d = %v, want %s", i, source, address)
+		}
+	}
+
+	if source := pool.allocate(supportAmount, fees); source != nil {
+		t.Errorf("allocate() = %s, want nil when balance is not enough", source.address.Address)
+	}
+
+	account := &openwallet.AssetsAccount{AccountID: "fees"}
+	if _, err := allocateFeesSupport(pool, account, supportAmount, fees); err == nil {
+		t.Errorf("allocateFeesSupport() want insufficient fees error")
+	}
+}
+
+func TestFeesSupportAmount(t *testing.T) {
+	fees := decimal.New(10000000, 0)
+	tests := []struct {
+		fix     string
+		scale   string
+		want    string
+		wantErr bool
+	}{
+		{want: "10000000"},
+		{fix: "0.5", want: "50000000"},
+		{fix: "0.5", scale: "3", want: "50000000"},
+		{fix: "0", scale: "1.5", want: "15000000"},
+		{scale: "2", want: "20000000"},
+		{fix: "0.000000001", wantErr: true},
+		{fix: "abc", wantErr: true},
+		{fix: "-1", wantErr: true},
+		{scale: "x", wantErr: true},
+		{scale: "-2", wantErr: true},
+		{scale: "0.000000001", wantErr: true},
+	}
+	for _, test := range tests {
+		feesSupport := &openwallet.FeesSupportAccount{FixSupportAmount: test.fix, FeesSupportScale: test.scale}
+		got, err := feesSupportAmount(feesSupport, fees, 8)
+		if (err != nil) != test.wantErr {
+			t.Errorf("feesSupportAmount(%q, %q) error = %v, wantErr %v", test.fix, test.scale, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && got.String() != test.want {
+			t.Errorf("feesSupportAmount(%q, %q) = %s, want %s", test.fix, test.scale, got.String(), test.want)
+		}
+	}
+}
+
+//feesSupportTestWallet 只有手续费支持账户的钱包
+type feesSupportTestWallet struct {
+	summaryTestWallet
+	account *openwallet.AssetsAccount
+}
+
+func (w *feesSupportTestWallet) GetAssetsAccountInfo(accountID string) (*openwallet.AssetsAccount, error) {
+	if accountID != w.account.AccountID {
+		return nil, fmt.Errorf("account not found")
+	}
+	return w.account, nil
+}
+
+func TestTransactionDecoder_CreateFeesSupportForTransfer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/accounts/getBalance" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"success":true,"balance":100000000}`)
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = rpc.NewClient(server.URL)
+	decoder := wm.TxDecoder.(*TransactionDecoder)
+
+	wrapper := &feesSupportTestWallet{
+		summaryTestWallet: summaryTestWallet{addresses: []*openwallet.Address{
+			{AccountID: "fees", Address: "NFeesSupportAddress", PublicKey: "d67925c8c7fda675b4bf8e3230d2fccafd9c32be6414059bc3aa4bbb87d88548"},
+		}},
+		account: &openwallet.AssetsAccount{AccountID: "fees"},
+	}
+
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: "NSG", IsContract: true, ContractID: "cny", Contract: openwallet.SmartContract{Address: "NSG.CNY", Decimals: 2}},
+		Account: &openwallet.AssetsAccount{AccountID: "user"},
+		To:      map[string]string{"NEXaVgHXGdrAW5i1wyyxBJrVJhH3HdmD7f": "1.5"},
+	}
+	rawTx.SetExtParam("memo", "uid:1")
+	before := rawTx.GetExtParam().Raw
+
+	feesSupport := &openwallet.FeesSupportAccount{AccountID: "fees", FixSupportAmount: "0.2"}
+	err := decoder.createFeesSupportForTransfer(wrapper, rawTx, feesSupport, "NTokenAddress", decimal.New(1, -1))
+
+	supportErr, ok := err.(*FeesSupportError)
+	if !ok {
+		t.Fatalf("createFeesSupportForTransfer() error = %v, want FeesSupportError", err)
+	}
+	if supportErr.Code() != openwallet.ErrInsufficientFees || supportErr.Address != "NTokenAddress" {
+		t.Errorf("FeesSupportError code = %d, address = %s", supportErr.Code(), supportErr.Address)
+	}
+
+	//原交易单不变
+	if rawTx.IsBuilt || len(rawTx.RawHex) > 0 || rawTx.Coin.Contract.Address != "NSG.CNY" || rawTx.GetExtParam().Raw != before {
+		t.Errorf("rawTx of token transfer is changed: %+v", rawTx)
+	}
+
+	supportTx := supportErr.SupportTx
+	if supportTx == nil || !supportTx.IsBuilt || supportTx.Coin.IsContract {
+		t.Fatalf("support transaction = %+v", supportTx)
+	}
+	if supportTx.To["NTokenAddress"] != "0.20000000" || supportTx.Fees != "0.10000000" {
+		t.Errorf("support transaction to = %v, fees = %s", supportTx.To, supportTx.Fees)
+	}
+	ext := supportTx.GetExtParam()
+	if ext.Get("memo").Exists() {
+		t.Errorf("support transaction should not copy memo: %s", ext.Raw)
+	}
+	if ext.Get("feesSupportFor.address").String() != "NTokenAddress" || ext.Get("feesSupportFor.accountID").String() != "user" {
+		t.Errorf("support transaction feesSupportFor = %s", ext.Get("feesSupportFor").Raw)
+	}
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/config"
+	"github.com/blocktree/nasgo-adapter/rpc"
+)
+
+//feeTestNode 模拟节点的手续费接口，down为true时接口不可用
+type feeTestNode struct {
+	mu       sync.Mutex
+	transfer uint64
+	down     bool
+	requests int
+	*httptest.Server
+}
+
+func newFeeTestNode() *feeTestNode {
+	node := &feeTestNode{transfer: 10000000}
+	node.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		node.mu.Lock()
+		defer node.mu.Unlock()
+		node.requests++
+		if node.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"success":false,"error":"node is busy"}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/blocks/getFee":
+			fmt.Fprintf(w, `{"success":true,"fee":%d}`, node.transfer)
+		case "/api/accounts/delegates/fee":
+			fmt.Fprint(w, `{"success":true,"fee":100000000}`)
+		case "/api/delegates/fee":
+			fmt.Fprint(w, `{"success":true,"fee":10000000000}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return node
+}
+
+func (node *feeTestNode) set(fn func(node *feeTestNode)) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	fn(node)
+}
+
+func (node *feeTestNode) requestCount() int {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	return node.requests
+}
+
+func TestWalletManager_GetFeeSchedule_Cache(t *testing.T) {
+	node := newFeeTestNode()
+	defer node.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = rpc.NewClient(node.URL)
+
+	schedule, err := wm.GetFeeSchedule()
+	if err != nil {
+		t.Fatalf("GetFeeSchedule() failed: %v", err)
+	}
+	if schedule.Transfer != 10000000 || schedule.Asset != 10000000 || schedule.Vote != 100000000 || schedule.Delegate != 10000000000 {
+		t.Errorf("GetFeeSchedule() = %+v", schedule)
+	}
+	requests := node.requestCount()
+
+	//有效期内使用缓存
+	node.set(func(node *feeTestNode) { node.transfer = 20000000 })
+	if schedule, _ := wm.GetFeeSchedule(); schedule.Transfer != 10000000 || node.requestCount() != requests {
+		t.Errorf("GetFeeSchedule() in ttl = %+v, requests = %d, want cached", schedule, node.requestCount()-requests)
+	}
+
+	//过期后重新获取
+	wm.fees.updatedAt = time.Now().Add(-feeCacheExpired - time.Second)
+	if schedule, _ := wm.GetFeeSchedule(); schedule.Transfer != 20000000 {
+		t.Errorf("GetFeeSchedule() after ttl = %+v, want refreshed", schedule)
+	}
+
+	//过期后节点不可用，沿用旧的手续费表
+	wm.fees.updatedAt = time.Now().Add(-feeCacheExpired - time.Second)
+	node.set(func(node *feeTestNode) { node.down = true })
+	schedule, err = wm.GetFeeSchedule()
+	if err != nil || schedule.Transfer != 20000000 {
+		t.Errorf("GetFeeSchedule() with node down = %+v, err = %v, want cached", schedule, err)
+	}
+
+	//没有缓存时返回错误
+	if _, err := NewWalletManager().GetFeeSchedule(); err == nil {
+		t.Errorf("GetFeeSchedule() without node want error")
+	}
+}
+
+func TestWalletManager_GetTransactionFee(t *testing.T) {
+	tests := []struct {
+		name        string
+		txType      uint32
+		fixFees     string
+		networkFees string
+		down        bool
+		want        string
+		wantErr     bool
+	}{
+		{name: "transfer", txType: rpc.TxType_NSG, fixFees: "0", want: "0.1"},
+		{name: "asset", txType: rpc.TxType_Asset, fixFees: "0", want: "0.1"},
+		{name: "vote", txType: rpc.TxType_Vote, fixFees: "0", want: "1"},
+		{name: "delegate", txType: rpc.TxType_Delegate, fixFees: "0", want: "100"},
+		{name: "unsupported type", txType: 99, fixFees: "0", wantErr: true},
+		{name: "fixFees override", txType: rpc.TxType_Vote, fixFees: "0.5", down: true, want: "0.5"},
+		{name: "empty fixFees", txType: rpc.TxType_NSG, want: "0.1"},
+		{name: "malformed fixFees", txType: rpc.TxType_NSG, fixFees: "0.1a", wantErr: true},
+		{name: "negative fixFees", txType: rpc.TxType_NSG, fixFees: "-0.1", wantErr: true},
+		{name: "network default", txType: rpc.TxType_Asset, fixFees: "0", networkFees: "0.01", down: true, want: "0.01"},
+		{name: "no network default for vote", txType: rpc.TxType_Vote, fixFees: "0", networkFees: "0.01", down: true, wantErr: true},
+		{name: "no network default", txType: rpc.TxType_NSG, fixFees: "0", down: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := newFeeTestNode()
+			defer node.Close()
+			node.down = tt.down
+
+			wm := NewWalletManager()
+			wm.WalletClient = rpc.NewClient(node.URL)
+			wm.Config.FixFees = tt.fixFees
+			wm.Config.Network.Fees = tt.networkFees
+
+			fee, err := wm.GetTransactionFee(tt.txType)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("GetTransactionFee() = %s, want error", fee.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetTransactionFee() failed: %v", err)
+			}
+			if fee.String() != tt.want {
+				t.Errorf("GetTransactionFee() = %s, want %s", fee.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWalletManager_LoadAssetsConfig_FixFees(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	load := func(fixFees string) error {
+		c, err := config.NewConfigData("ini", []byte(fmt.Sprintf("serverAPI = %s\nfixFees = %s\ndataDir = %s\n", down.URL, fixFees, t.TempDir())))
+		if err != nil {
+			t.Fatalf("NewConfigData failed: %v", err)
+		}
+		return NewWalletManager().LoadAssetsConfig(c)
+	}
+
+	if err := load("0.1"); err != nil {
+		t.Fatalf("LoadAssetsConfig() with fixFees = 0.1 failed: %v", err)
+	}
+	for _, fixFees := range []string{"abc", "-1", "0.000000001"} {
+		if err := load(fixFees); err == nil {
+			t.Errorf("LoadAssetsConfig() with fixFees = %s want error", fixFees)
+		}
+	}
+}
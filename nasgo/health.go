@@ -0,0 +1,106 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+	"time"
+)
+
+//节点健康状态
+const (
+	NodeStatusOK          = "ok"          //正常
+	NodeStatusUnreachable = "unreachable" //节点不可达
+	NodeStatusSyncing     = "syncing"     //节点同步中
+	NodeStatusBehind      = "behind"      //最新区块过旧，节点落后
+)
+
+//NodeHealth 节点健康检查结果
+type NodeHealth struct {
+	Status     string //节点状态
+	Reachable  bool   //是否可达
+	Syncing    bool   //是否同步中
+	Height     uint64 //节点高度
+	BlockTime  int64  //最新区块时间戳，链上时间
+	BlockAge   int64  //最新区块距今秒数，按节点时钟计算
+	PeerCount  int    //连接节点数
+	ClockDrift int64  //本机时钟减去节点时钟，秒
+	Error      string //不可达原因
+}
+
+//Healthy 节点是否可用于扫块
+func (health *NodeHealth) Healthy() bool {
+	return health.Status == NodeStatusOK
+}
+
+//String 状态描述
+func (health *NodeHealth) String() string {
+	if !health.Reachable {
+		return fmt.Sprintf("node %s: %s", health.Status, health.Error)
+	}
+	return fmt.Sprintf("node %s, height: %d, block age: %ds, peers: %d, clock drift: %ds",
+		health.Status, health.Height, health.BlockAge, health.PeerCount, health.ClockDrift)
+}
+
+//Health 检查节点的可达性、高度、最新区块时间、连接数和时钟偏差
+func (wm *WalletManager) Health() *NodeHealth {
+
+	health := &NodeHealth{Status: NodeStatusUnreachable}
+	client := wm.WalletClient
+
+	status, err := client.Block.GetStatus()
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	health.Reachable = true
+	health.Height = status.Height
+
+	if nodeTime, err := client.Peer.GetNodeTime(); err == nil {
+		health.ClockDrift = time.Now().Unix() - nodeTime.Unix()
+	}
+
+	if status.Height > 0 {
+		header, err := client.Block.GetByHeight(status.Height)
+		if err != nil {
+			health.Reachable = false
+			health.Error = err.Error()
+			return health
+		}
+		health.BlockTime = header.Timestamp
+		//按节点时钟计算，本机时钟偏差不影响落后判断
+		health.BlockAge = wm.Config.GetEpochTime() - health.ClockDrift - header.Timestamp
+	}
+
+	if syncStatus, err := client.Peer.GetSyncStatus(); err == nil {
+		health.Syncing = syncStatus.Syncing
+	}
+
+	if peerCount, err := client.Peer.GetPeerCount(); err == nil {
+		health.PeerCount = peerCount
+	}
+
+	switch {
+	case health.Syncing:
+		health.Status = NodeStatusSyncing
+	case health.Height == 0 || health.BlockAge > wm.Config.MaxBlockAge:
+		health.Status = NodeStatusBehind
+	default:
+		health.Status = NodeStatusOK
+	}
+
+	return health
+}
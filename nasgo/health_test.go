@@ -0,0 +1,176 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+)
+
+//healthTestNode 模拟节点状态，blockAge为最新区块距节点时钟的秒数，clockBehind为节点时钟落后本机的秒数
+type healthTestNode struct {
+	mu          sync.Mutex
+	requests    map[string]int
+	blockAge    int64
+	clockBehind int64
+	syncing     bool
+	*httptest.Server
+}
+
+func newHealthTestNode(wm *WalletManager, blockAge, clockBehind int64, syncing bool) *healthTestNode {
+	node := &healthTestNode{
+		requests:    make(map[string]int),
+		blockAge:    blockAge,
+		clockBehind: clockBehind,
+		syncing:     syncing,
+	}
+	node.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		node.mu.Lock()
+		node.requests[r.URL.Path]++
+		node.mu.Unlock()
+
+		nodeTime := time.Now().Add(-time.Duration(node.clockBehind) * time.Second)
+		w.Header().Set("Date", nodeTime.UTC().Format(http.TimeFormat))
+		switch r.URL.Path {
+		case "/api/blocks/getStatus":
+			fmt.Fprint(w, `{"success":true,"height":61}`)
+		case "/api/blocks/getHeight":
+			fmt.Fprint(w, `{"success":true,"height":62}`)
+		case "/api/blocks/get":
+			timestamp := wm.Config.GetEpochTime() - node.clockBehind - node.blockAge
+			fmt.Fprintf(w, `{"success":true,"block":{"id":"block61","height":61,"timestamp":%d,"previousBlock":"block60"}}`, timestamp)
+		case "/api/loader/status/sync":
+			fmt.Fprintf(w, `{"success":true,"syncing":%t}`, node.syncing)
+		case "/api/peers":
+			fmt.Fprint(w, `{"success":true,"totalCount":3}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return node
+}
+
+func (node *healthTestNode) requestsOf(path string) int {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	return node.requests[path]
+}
+
+func TestWalletManager_Health(t *testing.T) {
+	tests := []struct {
+		name        string
+		blockAge    int64
+		clockBehind int64
+		syncing     bool
+		down        bool
+		wantStatus  string
+	}{
+		{name: "ok", blockAge: 10, wantStatus: NodeStatusOK},
+		{name: "unreachable", down: true, wantStatus: NodeStatusUnreachable},
+		{name: "behind", blockAge: 600, wantStatus: NodeStatusBehind},
+		{name: "syncing", blockAge: 600, syncing: true, wantStatus: NodeStatusSyncing},
+		//本机时钟快于节点，最新区块按节点时钟仍是新的
+		{name: "local clock ahead", blockAge: 10, clockBehind: 300, wantStatus: NodeStatusOK},
+		{name: "local clock ahead, node behind", blockAge: 600, clockBehind: 300, wantStatus: NodeStatusBehind},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm := NewWalletManager()
+			node := newHealthTestNode(wm, tt.blockAge, tt.clockBehind, tt.syncing)
+			if tt.down {
+				node.Close()
+			} else {
+				defer node.Close()
+			}
+			wm.WalletClient = rpc.NewClient(node.URL)
+
+			health := wm.Health()
+			if health.Status != tt.wantStatus {
+				t.Fatalf("Health() status = %s, want %s: %s", health.Status, tt.wantStatus, health.String())
+			}
+			if health.Healthy() != (tt.wantStatus == NodeStatusOK) {
+				t.Errorf("Healthy() = %v with status %s", health.Healthy(), health.Status)
+			}
+
+			if tt.down {
+				if health.Reachable || len(health.Error) == 0 {
+					t.Errorf("unreachable health = %+v, want error", health)
+				}
+				return
+			}
+
+			if !health.Reachable || health.Height != 61 || health.PeerCount != 3 {
+				t.Errorf("Health() = %+v, want reachable at 61 with 3 peers", health)
+			}
+			//Date头精确到秒
+			if drift := health.ClockDrift - tt.clockBehind; drift < 0 || drift > 1 {
+				t.Errorf("ClockDrift = %d, want %d", health.ClockDrift, tt.clockBehind)
+			}
+			if age := health.BlockAge - tt.blockAge; age < -1 || age > 1 {
+				t.Errorf("BlockAge = %d, want %d", health.BlockAge, tt.blockAge)
+			}
+		})
+	}
+}
+
+func TestBlockScanner_ScanBlockTask_PauseOnUnhealthyNode(t *testing.T) {
+	tests := []struct {
+		name       string
+		down       bool
+		wantStatus string
+	}{
+		{name: "unreachable", down: true, wantStatus: NodeStatusUnreachable},
+		{name: "behind", wantStatus: NodeStatusBehind},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm := NewWalletManager()
+			node := newHealthTestNode(wm, 600, 0, false)
+			if tt.down {
+				node.Close()
+			} else {
+				defer node.Close()
+			}
+
+			bs, dai := newRangeTestScanner(node.URL, 4)
+			bs.Scanning = true
+
+			bs.ScanBlockTask()
+
+			if bs.NodeHealth == nil || bs.NodeHealth.Status != tt.wantStatus {
+				t.Fatalf("NodeHealth = %+v, want %s", bs.NodeHealth, tt.wantStatus)
+			}
+			if len(dai.heads) != 0 || dai.writes != 0 {
+				t.Errorf("paused scanner committed heights %v", dai.heads)
+			}
+			//健康检查之外不再请求区块
+			if got := node.requestsOf("/api/blocks/get"); got > 1 {
+				t.Errorf("paused scanner requested %d blocks", got)
+			}
+			if got := node.requestsOf("/api/transactions"); got != 0 {
+				t.Errorf("paused scanner requested %d block transactions", got)
+			}
+		})
+	}
+}
@@ -16,6 +16,8 @@
 package nasgo
 
 import (
+	"sync"
+
 	"github.com/blocktree/nasgo-adapter/rpc"
 	"github.com/blocktree/openwallet/v2/log"
 	"github.com/blocktree/openwallet/v2/openwallet"
@@ -28,13 +30,16 @@ const (
 type WalletManager struct {
 	openwallet.AssetsAdapterBase
 
-	WalletClient    *rpc.Client                     // 节点客户端
-	Config          *WalletConfig                   //钱包管理配置
-	Blockscanner    *BlockScanner                   //区块扫描器
-	Decoder         *AddressDecoder                 //地址编码器
-	TxDecoder       openwallet.TransactionDecoder   //交易单编码器
-	ContractDecoder openwallet.SmartContractDecoder //智能合约解析器
-	Log             *log.OWLogger                   //日志工具
+	WalletClient    *rpc.Client                   // 节点客户端
+	Config          *WalletConfig                 //钱包管理配置
+	Blockscanner    *BlockScanner                 //区块扫描器
+	Decoder         *AddressDecoder               //地址编码器
+	TxDecoder       openwallet.TransactionDecoder //交易单编码器
+	ContractDecoder *ContractDecoder              //智能合约解析器
+	Log             *log.OWLogger                 //日志工具
+	TxTracker       *TxTracker                    //交易单确认追踪
+	fees            feeCache                      //节点手续费缓存
+	networkMu       sync.Mutex                    //网络参数从节点取得时加锁
 }
 
 func NewWalletManager() *WalletManager {
@@ -47,5 +52,6 @@ func NewWalletManager() *WalletManager {
 	wm.TxDecoder = NewTransactionDecoder(&wm)
 	wm.Log = log.NewOWLogger(wm.Symbol())
 	wm.ContractDecoder = NewContractDecoder(&wm)
+	wm.TxTracker = NewTxTracker(&wm)
 	return &wm
 }
@@ -16,6 +16,11 @@
 package nasgo
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
 	"github.com/blocktree/openwallet/v2/log"
 	"github.com/blocktree/openwallet/v2/openwallet"
 	"path/filepath"
@@ -67,3 +72,88 @@ func TestContractDecoder_GetTokenBalanceByAddress(t *testing.T) {
 		log.Infof("token: %+v", t.Balance)
 	}
 }
+
+func TestWalletManager_LoadAssetsConfig_NodeUnreachable(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	c, err := config.NewConfigData("ini", []byte(fmt.Sprintf("serverAPI = %s\nisTestNet = true\naddressPrefix = T\nchainEpoch = 1600000000\ndataDir = %s\n", down.URL, t.TempDir())))
+	if err != nil {
+		t.Fatalf("NewConfigData failed: %v", err)
+	}
+
+	wm := NewWalletManager()
+	if err := wm.LoadAssetsConfig(c); err != nil {
+		t.Fatalf("LoadAssetsConfig with unreachable node failed: %v", err)
+	}
+
+	if len(wm.Config.Network.Magic) > 0 {
+		t.Fatalf("network magic = %s, want empty before broadcast", wm.Config.Network.Magic)
+	}
+
+	magic := "594fe0f3"
+
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/blocks/getNethash" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"success":true,"nethash":"%s"}`, magic)
+	}))
+	defer node.Close()
+
+	wm.WalletClient = rpc.NewClient(node.URL)
+	if err := wm.ensureNetwork(); err != nil {
+		t.Fatalf("ensureNetwork failed: %v", err)
+	}
+	if wm.Config.Network.Magic != magic {
+		t.Errorf("network magic = %s, want %s", wm.Config.Network.Magic, magic)
+	}
+}
+
+func TestWalletManager_LoadAssetsConfig_TestNetParams(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	load := func(extra string) (*WalletManager, error) {
+		c, err := config.NewConfigData("ini", []byte(fmt.Sprintf("serverAPI = %s\nisTestNet = true\ndataDir = %s\n%s", down.URL, t.TempDir(), extra)))
+		if err != nil {
+			t.Fatalf("NewConfigData failed: %v", err)
+		}
+		wm := NewWalletManager()
+		return wm, wm.LoadAssetsConfig(c)
+	}
+
+	if _, err := load(""); err == nil {
+		t.Errorf("LoadAssetsConfig of testnet without addressPrefix should fail")
+	}
+	if _, err := load("addressPrefix = T\n"); err == nil {
+		t.Errorf("LoadAssetsConfig of testnet without chainEpoch should fail")
+	}
+
+	wm, err := load("addressPrefix = T\nchainEpoch = 1600000000\n")
+	if err != nil {
+		t.Fatalf("LoadAssetsConfig failed: %v", err)
+	}
+	if wm.Config.Network.Name != TestNetParams.Name {
+		t.Errorf("network = %s, want %s", wm.Config.Network.Name, TestNetParams.Name)
+	}
+	if wm.Config.Network.Epoch == MainNetParams.Epoch {
+		t.Errorf("testnet epoch should not be the mainnet epoch")
+	}
+
+	pub := make([]byte, 32)
+	addr, err := wm.Decoder.PublicKeyToAddress(pub, true)
+	if err != nil {
+		t.Fatalf("PublicKeyToAddress failed: %v", err)
+	}
+	mainAddr, _ := NewWalletManager().Decoder.PublicKeyToAddress(pub, false)
+	if addr[:1] != "T" || addr[1:] != mainAddr[1:] {
+		t.Errorf("testnet address = %s, want prefix T of %s", addr, mainAddr)
+	}
+
+	//测试网没有默认手续费，节点不可用时不能回退到主网手续费
+	if _, err := wm.GetTransactionFee(rpc.TxType_NSG); err == nil {
+		t.Errorf("GetTransactionFee of testnet without node should fail")
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//备注的输入编码，由扩展参数memoEncoding指定
+const (
+	MemoEncodingUTF8   = "utf8"   //原文，默认
+	MemoEncodingHex    = "hex"    //十六进制
+	MemoEncodingBase64 = "base64" //标准base64
+)
+
+//decodeMemo 按编码解析备注，并检查是否为UTF-8文本且不超过maxLength字节
+func decodeMemo(memo, encoding string, maxLength int) (string, error) {
+
+	var message []byte
+
+	switch strings.ToLower(encoding) {
+	case "", MemoEncodingUTF8:
+		message = []byte(memo)
+	case MemoEncodingHex:
+		b, err := hex.DecodeString(strings.TrimPrefix(memo, "0x"))
+		if err != nil {
+			return "", openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "memo is not valid hex: %v", err)
+		}
+		message = b
+	case MemoEncodingBase64:
+		b, err := base64.StdEncoding.DecodeString(memo)
+		if err != nil {
+			return "", openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "memo is not valid base64: %v", err)
+		}
+		message = b
+	default:
+		return "", openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "invalid memo encoding: %s", encoding)
+	}
+
+	//节点以JSON字符串接收备注，非UTF-8内容会被改写，导致签名失效
+	if !utf8.Valid(message) {
+		return "", openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "memo is not valid UTF-8 text")
+	}
+
+	if maxLength > 0 && len(message) > maxLength {
+		return "", openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "memo length %d bytes exceeds the limit %d bytes", len(message), maxLength)
+	}
+
+	return string(message), nil
+}
+
+//getTxMessage 交易单扩展参数memo解析后的备注
+func (decoder *TransactionDecoder) getTxMessage(rawTx *openwallet.RawTransaction) (string, error) {
+	ext := rawTx.GetExtParam()
+	return decodeMemo(ext.Get("memo").String(), ext.Get("memoEncoding").String(), decoder.wm.Config.Network.MaxMessage)
+}
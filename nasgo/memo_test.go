@@ -0,0 +1,57 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeMemo(t *testing.T) {
+	tests := []struct {
+		memo     string
+		encoding string
+		want     string
+		wantErr  bool
+	}{
+		{memo: "", want: ""},
+		{memo: "dep10086", want: "dep10086"},
+		{memo: "充值", encoding: MemoEncodingUTF8, want: "充值"},
+		{memo: "0x3130303836", encoding: MemoEncodingHex, want: "10086"},
+		{memo: "3130303836", encoding: "HEX", want: "10086"},
+		{memo: "MTAwODY=", encoding: MemoEncodingBase64, want: "10086"},
+		{memo: "zz", encoding: MemoEncodingHex, wantErr: true},
+		{memo: "ff", encoding: MemoEncodingHex, wantErr: true},
+		{memo: "!!", encoding: MemoEncodingBase64, wantErr: true},
+		{memo: "10086", encoding: "rot13", wantErr: true},
+		{memo: strings.Repeat("a", 8), want: strings.Repeat("a", 8)},
+		{memo: strings.Repeat("a", 9), wantErr: true},
+		{memo: "充值充值", wantErr: true},
+	}
+
+	for i, test := range tests {
+		got, err := decodeMemo(test.memo, test.encoding, 8)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("case %d: decodeMemo() = %q, want error", i, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("case %d: decodeMemo() = %q, %v, want %q", i, got, err, test.want)
+		}
+	}
+}
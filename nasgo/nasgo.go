@@ -16,8 +16,11 @@
 package nasgo
 
 import (
+	"fmt"
+
 	"github.com/astaxie/beego/config"
 	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/nasgo-adapter/utils"
 	"github.com/blocktree/openwallet/v2/log"
 	"github.com/blocktree/openwallet/v2/openwallet"
 )
@@ -62,13 +65,166 @@ func (wm *WalletManager) LoadAssetsConfig(c config.Configer) error {
 
 	wm.Config.ServerAPI = c.String("serverAPI")
 	wm.Config.IsTestNet, _ = c.Bool("isTestNet")
+
+	//网络参数
+	if wm.Config.IsTestNet {
+		wm.Config.SetNetworkParams(TestNetParams)
+	} else {
+		wm.Config.SetNetworkParams(MainNetParams)
+	}
+	if prefix := c.String("addressPrefix"); len(prefix) > 0 {
+		wm.Config.Network.AddressPrefix = prefix
+	}
+	if magic := c.String("networkMagic"); len(magic) > 0 {
+		wm.Config.Network.Magic = magic
+	}
+	if version := c.String("networkVersion"); len(version) > 0 {
+		wm.Config.Network.Version = version
+	}
+	if epoch, err := c.Int64("chainEpoch"); err == nil && epoch > 0 {
+		wm.Config.Network.Epoch = epoch
+	}
+	if maxMessage, err := c.Int("maxMessageLength"); err == nil && maxMessage > 0 {
+		wm.Config.Network.MaxMessage = maxMessage
+	}
+	if fees := c.String("networkFees"); len(fees) > 0 {
+		if _, err := utils.ParseAmount(fees, wm.Decimal()); err != nil {
+			return fmt.Errorf("invalid networkFees: %v", err)
+		}
+		wm.Config.Network.Fees = fees
+	}
+	if err := wm.Config.Network.check(); err != nil {
+		return err
+	}
+
 	wm.WalletClient = rpc.NewClient(wm.Config.ServerAPI)
 	wm.Config.DataDir = c.String("dataDir")
 	wm.Config.FixFees = c.String("fixFees")
+	if len(wm.Config.FixFees) > 0 {
+		if _, err := utils.ParseAmount(wm.Config.FixFees, wm.Decimal()); err != nil {
+			return fmt.Errorf("invalid fixFees: %v", err)
+		}
+	}
 	wm.Config.RpcRetry, _ = c.Int64("rpcRetry")
+	if txValidWindow, err := c.Int64("txValidWindow"); err == nil && txValidWindow > 0 {
+		wm.Config.TxValidWindow = txValidWindow
+	}
+	if source := c.String("timestampSource"); len(source) > 0 {
+		if source != TimestampSourceLocal && source != TimestampSourceNode {
+			return fmt.Errorf("invalid timestampSource: %s", source)
+		}
+		wm.Config.TimestampSource = source
+	}
+	if offset, err := c.Int64("timestampOffset"); err == nil {
+		wm.Config.TimestampOffset = offset
+	}
+	if maxBlockAge, err := c.Int64("maxBlockAge"); err == nil && maxBlockAge > 0 {
+		wm.Config.MaxBlockAge = maxBlockAge
+	}
+	if catchUpWindow, err := c.Int("catchUpWindow"); err == nil {
+		wm.Config.CatchUpWindow = catchUpWindow
+	}
+
+	if value := c.String("confirmThresholds"); len(value) > 0 {
+		thresholds, err := parseConfirmThresholds(value)
+		if err != nil {
+			return err
+		}
+		wm.Config.ConfirmThresholds = thresholds
+	}
+
+	wm.Config.TokenAllowList = parseTokenList(c.String("tokenAllowList"))
+	wm.Config.TokenDenyList = parseTokenList(c.String("tokenDenyList"))
+	wm.Config.TokenFilterByScanTarget, _ = c.Bool("tokenFilterByScanTarget")
+	if action := c.String("tokenFilterAction"); len(action) > 0 {
+		if action != TokenFilterActionSkip && action != TokenFilterActionTag {
+			return fmt.Errorf("invalid tokenFilterAction: %s", action)
+		}
+		wm.Config.TokenFilterAction = action
+	}
+
+	if policy := c.String("addressSelectPolicy"); len(policy) > 0 {
+		if err := checkAddressSelectPolicy(policy); err != nil {
+			return err
+		}
+		wm.Config.AddressSelectPolicy = policy
+	}
+
+	wm.Config.DepositTagMode, _ = c.Bool("depositTagMode")
+	wm.Config.DepositMemoFormat = c.String("depositMemoFormat")
+	memoRegexp, err := parseDepositMemoFormat(wm.Config.DepositMemoFormat)
+	if err != nil {
+		return err
+	}
+	wm.Config.depositMemoRegexp = memoRegexp
+	wm.Config.DepositFallbackKey = c.String("depositFallbackKey")
 
 	//数据文件夹
 	wm.Config.makeDataDir()
+
+	//检查节点网络与配置是否一致
+	if err := wm.CheckNetwork(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+//CheckNetwork 检查节点的nethash和版本是否与配置一致，节点不可达时只记录日志
+//magic未配置且节点不可达时，在首次广播前再从节点取得
+func (wm *WalletManager) CheckNetwork() error {
+
+	wm.networkMu.Lock()
+	defer wm.networkMu.Unlock()
+
+	nethash, err := wm.WalletClient.Peer.GetNethash()
+	if err != nil {
+		wm.Log.Std.Warning("can not get nethash of node; unexpected error: %v", err)
+	} else if len(wm.Config.Network.Magic) == 0 {
+		wm.Log.Std.Info("network magic is not configured, use the nethash of node: %s", nethash)
+		wm.Config.Network.Magic = nethash
+	} else if nethash != wm.Config.Network.Magic {
+		return fmt.Errorf("network magic mismatch, config: %s, node: %s", wm.Config.Network.Magic, nethash)
+	}
+
+	if len(wm.Config.Network.Magic) == 0 {
+		wm.Log.Std.Warning("network magic is not configured, it will be resolved from node before broadcast")
+	}
+
+	version, err := wm.WalletClient.Peer.GetVersion()
+	if err != nil {
+		wm.Log.Std.Warning("can not get version of node; unexpected error: %v", err)
+	} else {
+		wm.Log.Std.Info("node version: %s, build: %s, net: %s", version.Version, version.Build, version.Net)
+		if len(version.Net) > 0 && (version.Net == "testnet") != wm.Config.IsTestNet {
+			return fmt.Errorf("network mismatch, isTestNet: %v, node: %s", wm.Config.IsTestNet, version.Net)
+		}
+	}
+
+	wm.WalletClient.SetNetwork(wm.Config.Network.Magic, wm.Config.Network.Version)
+
+	return nil
+}
+
+//ensureNetwork 确保广播前已取得网络magic，未配置时从节点取得
+func (wm *WalletManager) ensureNetwork() error {
+
+	wm.networkMu.Lock()
+	defer wm.networkMu.Unlock()
+
+	if len(wm.Config.Network.Magic) > 0 {
+		return nil
+	}
+
+	nethash, err := wm.WalletClient.Peer.GetNethash()
+	if err != nil {
+		return fmt.Errorf("network magic is not configured and can not get nethash of node: %v", err)
+	}
+
+	wm.Log.Std.Info("network magic is not configured, use the nethash of node: %s", nethash)
+	wm.Config.Network.Magic = nethash
+	wm.WalletClient.SetNetwork(wm.Config.Network.Magic, wm.Config.Network.Version)
+
 	return nil
 }
 
@@ -0,0 +1,258 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/nasgo-adapter/utils"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	summaryBalanceWorkers = 10               //汇总时并发查询余额的数量
+	assetsBalanceCacheTTL = 30 * time.Second //地址UIA余额列表的缓存时间
+)
+
+//assetsBalanceCache 地址持有的全部UIA余额，key为currency
+type assetsBalanceCache struct {
+	balances  map[string]*rpc.AssetsBalance
+	fetchedAt time.Time
+}
+
+//SummaryCursor 汇总进度，用于分页汇总中断后继续
+type SummaryCursor struct {
+	StartIndex      int      `json:"startIndex"`      //本次汇总的地址开始位置
+	NextIndex       int      `json:"nextIndex"`       //下一页的地址开始位置
+	Completed       bool     `json:"completed"`       //已汇总到最后一页，NextIndex重置为0
+	FailedAddresses []string `json:"failedAddresses"` //查询余额失败，继续时需重试的地址
+}
+
+//summaryBalance 汇总地址的余额，链上整数
+type summaryBalance struct {
+	address     *openwallet.Address
+	balance     decimal.Decimal //汇总币种余额
+	coinBalance decimal.Decimal //主币余额
+	err         error
+}
+
+//summaryCursorKey 汇总进度按账户和币种区分
+func summaryCursorKey(accountID string, coin openwallet.Coin) string {
+	return accountID + "_" + coin.ContractID
+}
+
+//GetSummaryCursor 账户上一次汇总的进度，没有时返回nil
+//进度只保存在本进程内，调用方可序列化为JSON保存，下次汇总时通过扩展参数summaryCursor传回
+func (decoder *TransactionDecoder) GetSummaryCursor(accountID string, coin openwallet.Coin) *SummaryCursor {
+	decoder.cursorMu.Lock()
+	defer decoder.cursorMu.Unlock()
+	cursor, ok := decoder.summaryCursors[summaryCursorKey(accountID, coin)]
+	if !ok {
+		return nil
+	}
+	result := *cursor
+	result.FailedAddresses = append([]string{}, cursor.FailedAddresses...)
+	return &result
+}
+
+//saveSummaryCursor 记录汇总进度
+func (decoder *TransactionDecoder) saveSummaryCursor(sumRawTx *openwallet.SummaryRawTransaction, cursor *SummaryCursor) {
+	decoder.cursorMu.Lock()
+	defer decoder.cursorMu.Unlock()
+	decoder.summaryCursors[summaryCursorKey(sumRawTx.Account.AccountID, sumRawTx.Coin)] = cursor
+}
+
+//parseSummaryCursor 扩展参数summaryCursor传入的汇总进度，可以是JSON对象或JSON字符串，没有时返回nil
+func parseSummaryCursor(extParam string) (*SummaryCursor, error) {
+	value := gjson.Get(extParam, "summaryCursor")
+	if !value.Exists() {
+		return nil, nil
+	}
+	raw := value.Raw
+	if value.Type == gjson.String {
+		raw = value.String()
+	}
+	var cursor SummaryCursor
+	if err := json.Unmarshal([]byte(raw), &cursor); err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "invalid summaryCursor: %v", err)
+	}
+	if cursor.NextIndex < 0 {
+		return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "invalid summaryCursor: nextIndex %d", cursor.NextIndex)
+	}
+	return &cursor, nil
+}
+
+//getSummaryAddresses 本次汇总的地址
+//扩展参数summaryCursor传入调用方保存的进度时，先重试其中查询失败的地址，再从其下一页开始
+//没有传入进度而扩展参数resume为true时，采用本进程内记录的上次进度
+func (decoder *TransactionDecoder) getSummaryAddresses(wrapper openwallet.WalletDAI, sumRawTx *openwallet.SummaryRawTransaction) ([]*openwallet.Address, *SummaryCursor, error) {
+
+	var (
+		accountID = sumRawTx.Account.AccountID
+		start     = sumRawTx.AddressStartIndex
+		limit     = sumRawTx.AddressLimit
+		retry     []string
+		addresses = make([]*openwallet.Address, 0)
+		exist     = make(map[string]bool)
+	)
+
+	last, err := parseSummaryCursor(sumRawTx.ExtParam)
+	if err != nil {
+		return nil, nil, err
+	}
+	if last == nil && gjson.Get(sumRawTx.ExtParam, "resume").Bool() {
+		last = decoder.GetSummaryCursor(accountID, sumRawTx.Coin)
+	}
+	if last != nil {
+		start = last.NextIndex
+		retry = last.FailedAddresses
+	}
+
+	for _, address := range retry {
+		addr, err := wrapper.GetAddress(address)
+		if err != nil || addr == nil || addr.AccountID != accountID {
+			decoder.wm.Log.Notice("summary retry address [%s] is not found in account", address)
+			continue
+		}
+		exist[addr.Address] = true
+		addresses = append(addresses, addr)
+	}
+
+	page, err := wrapper.GetAddressList(start, limit, "AccountID", accountID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, addr := range page {
+		if !exist[addr.Address] {
+			exist[addr.Address] = true
+			addresses = append(addresses, addr)
+		}
+	}
+
+	cursor := &SummaryCursor{
+		StartIndex:      start,
+		NextIndex:       start + len(page),
+		FailedAddresses: make([]string, 0),
+	}
+	if limit <= 0 || len(page) < limit {
+		cursor.NextIndex = 0
+		cursor.Completed = true
+	}
+
+	return addresses, cursor, nil
+}
+
+//collectSummaryBalances 并发查询地址余额，结果与addresses顺序一致
+func (decoder *TransactionDecoder) collectSummaryBalances(addresses []*openwallet.Address, coin openwallet.Coin) []*summaryBalance {
+
+	var (
+		results = make([]*summaryBalance, len(addresses))
+		workers = make(chan struct{}, summaryBalanceWorkers)
+		wg      sync.WaitGroup
+	)
+
+	for i, addr := range addresses {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(index int, address *openwallet.Address) {
+			defer wg.Done()
+			results[index] = decoder.getSummaryBalance(address, coin)
+			<-workers
+		}(i, addr)
+	}
+	wg.Wait()
+
+	return results
+}
+
+//getSummaryBalance 查询地址的汇总币种余额和主币余额
+func (decoder *TransactionDecoder) getSummaryBalance(address *openwallet.Address, coin openwallet.Coin) *summaryBalance {
+
+	result := &summaryBalance{address: address, balance: decimal.Zero, coinBalance: decimal.Zero}
+
+	if !coin.IsContract {
+		b, err := decoder.wm.WalletClient.Wallet.GetBalance(address.Address)
+		if err != nil {
+			result.err = err
+			return result
+		}
+		result.coinBalance = utils.RawToDecimal(b, 0)
+		result.balance = result.coinBalance
+		return result
+	}
+
+	balances, err := decoder.getAssetsBalances(address.Address)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	//地址未持有该资产时余额为0
+	result.balance, result.err = balances[coin.Contract.Address].RawBalance()
+	if result.err != nil || !result.balance.GreaterThan(decimal.Zero) {
+		return result
+	}
+
+	//有代币余额才需要查询主币余额
+	b, err := decoder.wm.WalletClient.Wallet.GetBalance(address.Address)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.coinBalance = utils.RawToDecimal(b, 0)
+
+	return result
+}
+
+//getAssetsBalances 地址持有的全部UIA余额，一次调用取得，缓存期内汇总其他代币时不再查询
+func (decoder *TransactionDecoder) getAssetsBalances(address string) (map[string]*rpc.AssetsBalance, error) {
+
+	decoder.assetsMu.Lock()
+	cache, ok := decoder.assetsBalances[address]
+	decoder.assetsMu.Unlock()
+	if ok && time.Since(cache.fetchedAt) < assetsBalanceCacheTTL {
+		return cache.balances, nil
+	}
+
+	list, err := decoder.wm.WalletClient.Wallet.GetAssetsBalanceList(address)
+	if err != nil {
+		return nil, err
+	}
+
+	cache = &assetsBalanceCache{balances: make(map[string]*rpc.AssetsBalance), fetchedAt: time.Now()}
+	for _, balance := range list {
+		cache.balances[balance.Currency] = balance
+	}
+
+	decoder.assetsMu.Lock()
+	decoder.assetsBalances[address] = cache
+	decoder.assetsMu.Unlock()
+
+	return cache.balances, nil
+}
+
+//invalidateAssetsBalances 地址创建了交易单，余额将变化，清除缓存
+func (decoder *TransactionDecoder) invalidateAssetsBalances(address string) {
+	decoder.assetsMu.Lock()
+	delete(decoder.assetsBalances, address)
+	decoder.assetsMu.Unlock()
+}
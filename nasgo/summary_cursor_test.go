@@ -0,0 +1,191 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+type summaryTestWallet struct {
+	openwallet.WalletDAIBase
+	addresses []*openwallet.Address
+}
+
+func (w *summaryTestWallet) GetAddress(address string) (*openwallet.Address, error) {
+	for _, addr := range w.addresses {
+		if addr.Address == address {
+			return addr, nil
+		}
+	}
+	return nil, fmt.Errorf("address not found")
+}
+
+func (w *summaryTestWallet) GetAddressList(offset, limit int, cols ...interface{}) ([]*openwallet.Address, error) {
+	if offset >= len(w.addresses) {
+		return nil, nil
+	}
+	end := len(w.addresses)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return w.addresses[offset:end], nil
+}
+
+func TestTransactionDecoder_GetSummaryAddresses(t *testing.T) {
+	wm := NewWalletManager()
+	decoder := wm.TxDecoder.(*TransactionDecoder)
+	wrapper := &summaryTestWallet{}
+	for _, a := range []string{"A", "B", "C", "D", "E"} {
+		wrapper.addresses = append(wrapper.addresses, &openwallet.Address{Address: a, AccountID: "account"})
+	}
+	sumRawTx := &openwallet.SummaryRawTransaction{
+		Account:      &openwallet.AssetsAccount{AccountID: "account"},
+		AddressLimit: 2,
+	}
+
+	join := func(addresses []*openwallet.Address) string {
+		result := ""
+		for _, addr := range addresses {
+			result += addr.Address
+		}
+		return result
+	}
+
+	addresses, cursor, err := decoder.getSummaryAddresses(wrapper, sumRawTx)
+	if err != nil {
+		t.Fatalf("getSummaryAddresses() error = %v", err)
+	}
+	if got := join(addresses); got != "AB" || cursor.NextIndex != 2 || cursor.Completed {
+		t.Fatalf("first page = %s, cursor = %+v", got, cursor)
+	}
+	cursor.FailedAddresses = append(cursor.FailedAddresses, "B")
+	decoder.saveSummaryCursor(sumRawTx, cursor)
+
+	sumRawTx.ExtParam = `{"resume":true}`
+	addresses, cursor, _ = decoder.getSummaryAddresses(wrapper, sumRawTx)
+	if got := join(addresses); got != "BCD" || cursor.StartIndex != 2 || cursor.NextIndex != 4 {
+		t.Fatalf("resumed page = %s, cursor = %+v", got, cursor)
+	}
+	decoder.saveSummaryCursor(sumRawTx, cursor)
+
+	addresses, cursor, _ = decoder.getSummaryAddresses(wrapper, sumRawTx)
+	if got := join(addresses); got != "E" || !cursor.Completed || cursor.NextIndex != 0 {
+		t.Fatalf("last page = %s, cursor = %+v", got, cursor)
+	}
+}
+
+func TestTransactionDecoder_GetSummaryAddresses_ExtCursor(t *testing.T) {
+	wrapper := &summaryTestWallet{}
+	for _, a := range []string{"A", "B", "C", "D", "E"} {
+		wrapper.addresses = append(wrapper.addresses, &openwallet.Address{Address: a, AccountID: "account"})
+	}
+	sumRawTx := &openwallet.SummaryRawTransaction{
+		Account:      &openwallet.AssetsAccount{AccountID: "account"},
+		AddressLimit: 2,
+	}
+
+	first := NewWalletManager().TxDecoder.(*TransactionDecoder)
+	_, cursor, err := first.getSummaryAddresses(wrapper, sumRawTx)
+	if err != nil {
+		t.Fatalf("getSummaryAddresses() error = %v", err)
+	}
+	cursor.FailedAddresses = append(cursor.FailedAddresses, "A")
+
+	//调用方保存返回的进度，新的进程通过扩展参数传回
+	saved, _ := json.Marshal(cursor)
+	decoder := NewWalletManager().TxDecoder.(*TransactionDecoder)
+	for _, ext := range []string{
+		fmt.Sprintf(`{"summaryCursor":%s}`, saved),
+		fmt.Sprintf(`{"summaryCursor":%q}`, saved),
+	} {
+		sumRawTx.ExtParam = ext
+		addresses, next, err := decoder.getSummaryAddresses(wrapper, sumRawTx)
+		if err != nil {
+			t.Fatalf("getSummaryAddresses(%s) error = %v", ext, err)
+		}
+		got := ""
+		for _, addr := range addresses {
+			got += addr.Address
+		}
+		if got != "ACD" || next.StartIndex != 2 || next.NextIndex != 4 {
+			t.Errorf("getSummaryAddresses(%s) = %s, cursor = %+v", ext, got, next)
+		}
+	}
+
+	sumRawTx.ExtParam = `{"summaryCursor":{"nextIndex":"x"}}`
+	if _, _, err := decoder.getSummaryAddresses(wrapper, sumRawTx); err == nil {
+		t.Errorf("getSummaryAddresses() of invalid summaryCursor should fail")
+	}
+}
+
+func TestTransactionDecoder_CollectSummaryBalances_AssetsList(t *testing.T) {
+	var listCalls, balanceCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/uia/balances/"):
+			atomic.AddInt32(&listCalls, 1)
+			if strings.HasSuffix(r.URL.Path, "/A") {
+				fmt.Fprint(w, `{"success":true,"count":2,"balances":[{"currency":"NSG.CNY","balance":"500","precision":2},{"currency":"NSG.USD","balance":"7","precision":2}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"success":true,"count":0,"balances":[]}`)
+		case r.URL.Path == "/api/accounts/getBalance":
+			atomic.AddInt32(&balanceCalls, 1)
+			fmt.Fprint(w, `{"success":true,"balance":100}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = rpc.NewClient(server.URL)
+	decoder := wm.TxDecoder.(*TransactionDecoder)
+	addresses := []*openwallet.Address{{Address: "A"}, {Address: "B"}}
+	coinOf := func(currency string) openwallet.Coin {
+		return openwallet.Coin{IsContract: true, Contract: openwallet.SmartContract{Address: currency}}
+	}
+
+	results := decoder.collectSummaryBalances(addresses, coinOf("NSG.CNY"))
+	if results[0].err != nil || results[0].balance.String() != "500" || results[0].coinBalance.String() != "100" {
+		t.Errorf("balance of A = %+v", results[0])
+	}
+	if results[1].err != nil || !results[1].balance.IsZero() {
+		t.Errorf("balance of B = %+v", results[1])
+	}
+
+	//汇总其他代币复用余额列表，没有代币余额的地址不查询主币
+	decoder.collectSummaryBalances(addresses, coinOf("NSG.USD"))
+	if listCalls != 2 || balanceCalls != 2 {
+		t.Errorf("list calls = %d, balance calls = %d, want 2, 2", listCalls, balanceCalls)
+	}
+
+	//地址创建交易单后重新查询
+	decoder.invalidateAssetsBalances("A")
+	decoder.collectSummaryBalances(addresses, coinOf("NSG.CNY"))
+	if listCalls != 3 {
+		t.Errorf("list calls = %d, want 3", listCalls)
+	}
+}
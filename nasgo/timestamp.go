@@ -0,0 +1,95 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//交易单时间戳来源
+const (
+	TimestampSourceLocal = "local" //本机时间
+	TimestampSourceNode  = "node"  //节点最新区块时间
+)
+
+//GetNodeEpochTime 节点的链上时间，取最新区块的时间戳
+func (wm *WalletManager) GetNodeEpochTime() (int64, error) {
+
+	status, err := wm.WalletClient.Block.GetStatus()
+	if err != nil {
+		return 0, err
+	}
+
+	if status.Height == 0 {
+		return 0, fmt.Errorf("node has no block")
+	}
+
+	header, err := wm.WalletClient.Block.GetByHeight(status.Height)
+	if err != nil {
+		return 0, err
+	}
+
+	return header.Timestamp, nil
+}
+
+//GetTxTimestamp 交易单时间戳，优先使用扩展参数timestamp，否则按配置的时间源减去偏移
+func (wm *WalletManager) GetTxTimestamp(rawTx *openwallet.RawTransaction) (int64, error) {
+
+	if timestamp := rawTx.GetExtParam().Get("timestamp").Int(); timestamp > 0 {
+		return timestamp, nil
+	}
+
+	now := wm.Config.GetEpochTime()
+	if wm.Config.TimestampSource == TimestampSourceNode {
+		nodeTime, err := wm.GetNodeEpochTime()
+		if err != nil {
+			return 0, fmt.Errorf("can not get node time, unexpected error: %v", err)
+		}
+		now = nodeTime
+	}
+
+	return now - wm.Config.TimestampOffset, nil
+}
+
+//CheckTxTimestamp 检查交易单时间戳是否在节点的接受窗口内，返回不为空的提示信息
+func (wm *WalletManager) CheckTxTimestamp(timestamp int64) string {
+
+	now := wm.Config.GetEpochTime()
+	if wm.Config.TimestampSource == TimestampSourceNode {
+		nodeTime, err := wm.GetNodeEpochTime()
+		if err == nil {
+			now = nodeTime
+		}
+	}
+
+	if timestamp > now {
+		return fmt.Sprintf("transaction timestamp %d is %d seconds ahead of chain time", timestamp, timestamp-now)
+	}
+
+	remain := timestamp + wm.Config.TxValidWindow - now
+	if remain <= 0 {
+		return fmt.Sprintf("transaction timestamp %d is expired %d seconds ago", timestamp, -remain)
+	}
+
+	//剩余有效期不足十分之一
+	if remain < wm.Config.TxValidWindow/10 {
+		return fmt.Sprintf("transaction timestamp %d will expire in %d seconds", timestamp, remain)
+	}
+
+	return ""
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+func TestWalletManager_GetTxTimestamp(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.TimestampOffset = 30
+
+	rawTx := &openwallet.RawTransaction{}
+	got, err := wm.GetTxTimestamp(rawTx)
+	if err != nil {
+		t.Errorf("GetTxTimestamp() error = %v", err)
+		return
+	}
+	if now := wm.Config.GetEpochTime(); got > now-30 || got < now-31 {
+		t.Errorf("GetTxTimestamp() = %v, want %v", got, now-30)
+	}
+
+	rawTx.SetExtParam("timestamp", 59049090)
+	got, err = wm.GetTxTimestamp(rawTx)
+	if err != nil {
+		t.Errorf("GetTxTimestamp() error = %v", err)
+		return
+	}
+	if got != 59049090 {
+		t.Errorf("GetTxTimestamp() = %v, want %v", got, 59049090)
+	}
+}
+
+func TestWalletManager_CheckTxTimestamp(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.TxValidWindow = 600
+	now := wm.Config.GetEpochTime()
+
+	tests := []struct {
+		name      string
+		timestamp int64
+		wantWarn  bool
+	}{
+		{name: "valid", timestamp: now - 5, wantWarn: false},
+		{name: "future", timestamp: now + 60, wantWarn: true},
+		{name: "about to expire", timestamp: now - 590, wantWarn: true},
+		{name: "expired", timestamp: now - 700, wantWarn: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wm.CheckTxTimestamp(tt.timestamp); (len(got) > 0) != tt.wantWarn {
+				t.Errorf("CheckTxTimestamp() = %v, wantWarn %v", got, tt.wantWarn)
+			}
+		})
+	}
+}
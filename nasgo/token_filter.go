@@ -0,0 +1,118 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"strings"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//未登记资产转账的处理方式
+const (
+	TokenFilterActionSkip = "skip" //不提取资产转账，发送者的手续费照常提取
+	TokenFilterActionTag  = "tag"  //照常提取，交易单扩展参数unregisteredToken为true
+)
+
+//TokenFilterFunc 判断UIA资产是否已登记，返回false的资产转账按TokenFilterAction处理
+type TokenFilterFunc func(currency string) bool
+
+//UnregisteredToken 订阅地址收发的未登记资产
+type UnregisteredToken struct {
+	Currency    string
+	SourceKey   string //订阅的账户
+	TxID        string
+	BlockHeight uint64
+	BlockHash   string
+	From        string
+	To          string
+	Amount      string //链上整数数量
+}
+
+//UnregisteredTokenNotificationObject 未登记资产被通知对象，扫描器的观测者实现该接口即可收到通知
+type UnregisteredTokenNotificationObject interface {
+
+	//UnregisteredTokenNotify 未登记资产通知
+	UnregisteredTokenNotify(token *UnregisteredToken) error
+}
+
+//IsTokenRegistered 资产是否已登记
+//依次使用TokenFilterFunc、配置的允许列表、禁止列表、scanTargetFuncV2订阅的合约判断，都未配置时全部允许
+func (bs *BlockScanner) IsTokenRegistered(currency string, scanTargetFuncV2 openwallet.BlockScanTargetFuncV2) bool {
+
+	if bs.TokenFilterFunc != nil {
+		return bs.TokenFilterFunc(currency)
+	}
+
+	if list := bs.wm.Config.TokenAllowList; len(list) > 0 {
+		return containsToken(list, currency)
+	}
+
+	if list := bs.wm.Config.TokenDenyList; len(list) > 0 {
+		return !containsToken(list, currency)
+	}
+
+	if bs.wm.Config.TokenFilterByScanTarget && scanTargetFuncV2 != nil {
+		result := scanTargetFuncV2(openwallet.ScanTargetParam{
+			ScanTarget:     currency,
+			Symbol:         bs.wm.Symbol(),
+			ScanTargetType: openwallet.ScanTargetTypeContractAddress,
+		})
+		return result.Exist
+	}
+
+	return true
+}
+
+//newUnregisteredTokenNotify 通知未登记资产，通知失败不影响扫块
+func (bs *BlockScanner) newUnregisteredTokenNotify(tokens []*UnregisteredToken) {
+	if len(tokens) == 0 {
+		return
+	}
+	for o := range bs.Observers {
+		obj, ok := o.(UnregisteredTokenNotificationObject)
+		if !ok {
+			continue
+		}
+		for _, token := range tokens {
+			err := obj.UnregisteredTokenNotify(token)
+			if err != nil {
+				bs.wm.Log.Std.Error("UnregisteredTokenNotify unexpected error: %v", err)
+			}
+		}
+	}
+}
+
+func containsToken(list []string, currency string) bool {
+	for _, token := range list {
+		if token == currency {
+			return true
+		}
+	}
+	return false
+}
+
+//parseTokenList 解析逗号分隔的资产列表
+func parseTokenList(value string) []string {
+	list := make([]string, 0)
+	for _, token := range strings.Split(value, ",") {
+		token = strings.TrimSpace(token)
+		if len(token) > 0 {
+			list = append(list, token)
+		}
+	}
+	return list
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/blocktree/nasgo-adapter/rpc"
@@ -31,13 +32,21 @@ import (
 
 type TransactionDecoder struct {
 	openwallet.TransactionDecoderBase
-	wm *WalletManager //钱包管理者
+	wm             *WalletManager //钱包管理者
+	cursorMu       sync.Mutex
+	roundRobin     map[string]int            //账户轮询选择发送地址的起始位置
+	summaryCursors map[string]*SummaryCursor //账户汇总进度
+	assetsMu       sync.Mutex
+	assetsBalances map[string]*assetsBalanceCache //地址持有的全部UIA余额，汇总多个代币时复用
 }
 
 //NewTransactionDecoder 交易单解析器
 func NewTransactionDecoder(wm *WalletManager) *TransactionDecoder {
 	decoder := TransactionDecoder{}
 	decoder.wm = wm
+	decoder.roundRobin = make(map[string]int)
+	decoder.summaryCursors = make(map[string]*SummaryCursor)
+	decoder.assetsBalances = make(map[string]*assetsBalanceCache)
 	return &decoder
 }
 
@@ -103,13 +112,26 @@ func (decoder *TransactionDecoder) SubmitRawTransaction(wrapper openwallet.Walle
 		return nil, openwallet.ConvertError(err)
 	}
 
+	//检查时间戳是否在节点接受窗口内
+	if warning := decoder.wm.CheckTxTimestamp(trx.Timestamp); len(warning) > 0 {
+		decoder.wm.Log.Std.Warning("transaction [%s]: %s", trx.ID, warning)
+	}
+
 	param := map[string]interface{}{
 		"transaction": trx,
 	}
 
-	err = decoder.wm.WalletClient.Tx.BroadcastTx(param, decoder.wm.Config.RpcRetry)
+	if err := decoder.wm.ensureNetwork(); err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrSubmitRawTransactionFailed, "%v", err)
+	}
+
+	result, err := decoder.wm.WalletClient.Tx.Broadcast(param, decoder.wm.Config.RpcRetry)
 	if err != nil {
-		return nil, err
+		decoder.wm.Log.Std.Error("broadcast transaction [%s] failed, unexpected error: %v", trx.ID, err)
+		return nil, openwallet.Errorf(openwallet.ErrSubmitRawTransactionFailed, "%v", err)
+	}
+	if result.Status == rpc.BroadcastDuplicate {
+		decoder.wm.Log.Std.Info("transaction [%s] has been broadcast before: %s", trx.ID, result.Message)
 	}
 
 	rawTx.TxID = trx.ID
@@ -138,6 +160,9 @@ func (decoder *TransactionDecoder) SubmitRawTransaction(wrapper openwallet.Walle
 
 	tx.WxID = openwallet.GenTransactionWxID(tx)
 
+	//追踪交易单是否被打包
+	decoder.wm.TxTracker.Track(param, trx.Timestamp, tx)
+
 	return tx, nil
 }
 
@@ -153,71 +178,126 @@ func (decoder *TransactionDecoder) CreateNSGRawTransaction(wrapper openwallet.Wa
 		from      = &openwallet.Address{}
 		target    = ""
 		accountID = rawTx.Account.AccountID
-		limit     = 2000
 		isToken   = rawTx.Coin.IsContract
-		precision = int32(0)
+		precision = decoder.wm.Decimal()
 	)
 
 	if len(rawTx.To) == 0 {
 		return errors.New("Receiver address is empty")
 	}
 
-	addresses, err := wrapper.GetAddressList(0, limit, "AccountID", rawTx.Account.AccountID)
+	if isToken {
+		if err := decoder.wm.ContractDecoder.CheckContract(rawTx.Coin.Contract); err != nil {
+			return err
+		}
+		precision = int32(rawTx.Coin.Contract.Decimals)
+	}
+
+	addresses, err := decoder.getAccountAddresses(wrapper, accountID)
 	if err != nil {
 		return err
 	}
 
-	if len(addresses) == 0 {
-		return openwallet.Errorf(openwallet.ErrAccountNotAddress, "[%s] have not address", accountID)
+	//按选择策略排列候选地址
+	candidates, err := decoder.orderAddressesByPolicy(rawTx, addresses)
+	if err != nil {
+		return err
 	}
 
 	//计算总发送金额
 	for address, amount := range rawTx.To {
-		amt, _ := decimal.NewFromString(amount)
+		amt, err := utils.ParseAmount(amount, precision)
+		if err != nil {
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "send amount to [%s]: %v", address, err)
+		}
 		target = address
 		totalSend = totalSend.Add(amt)
 	}
 
 	if len(rawTx.FeeRate) == 0 {
-		fixFees, err = decimal.NewFromString(decoder.wm.Config.FixFees)
+		txType := uint32(rpc.TxType_NSG)
+		if isToken {
+			txType = rpc.TxType_Asset
+		}
+		fixFees, err = decoder.wm.GetTransactionFee(txType)
 		if err != nil {
 			return err
 		}
 	} else {
-		fixFees, _ = decimal.NewFromString(rawTx.FeeRate)
+		fixFees, err = utils.ParseAmount(rawTx.FeeRate, decoder.wm.Decimal())
+		if err != nil {
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "fee rate: %v", err)
+		}
 	}
 
 	decoder.wm.Log.Info("Calculating wallet unspent record to build transaction...")
-	computeTotalSend := totalSend.Add(fixFees)
+
+	//主币转账，余额需足够支付发送数额+手续费
+	//代币转账，代币余额需足够支付发送数额，主币余额需足够支付手续费
+	computeTotalSend := totalSend
+	if !isToken {
+		computeTotalSend = totalSend.Add(fixFees)
+	}
+
+	var (
+		found         = false
+		feesShortAddr *openwallet.Address //代币足够但主币不足支付手续费的地址
+		feesShortBal  = decimal.Zero
+		accountTotal  = decimal.Zero //所有地址的余额合计
+	)
 
 	//计算一个可用于支付的余额
-	for _, addr := range addresses {
+	for _, candidate := range candidates {
+		if err := decoder.fetchAddressBalance(candidate, rawTx.Coin); err != nil {
+			return err
+		}
+		addr := candidate.address
+		balance = candidate.balance
+		accountTotal = accountTotal.Add(balance)
+		if balance.LessThan(computeTotalSend) {
+			continue
+		}
+
 		if isToken {
-			coin := rawTx.Coin.Contract.Address
-			b, err := decoder.wm.WalletClient.Wallet.GetAssetsBalance(addr.Address, coin)
-			if err != nil {
-				return err
-			}
-			balance, _ = decimal.NewFromString(b.Balance)
-			balance = balance.Shift(-int32(b.Precision))
-			precision = int32(b.Precision)
-		} else {
 			b, err := decoder.wm.WalletClient.Wallet.GetBalance(addr.Address)
 			if err != nil {
 				return err
 			}
-			balance = decimal.New(int64(b), -decoder.wm.Decimal())
-			precision = int32(decoder.wm.Decimal())
-		}
-		if balance.GreaterThanOrEqual(computeTotalSend) {
-			from = addr
-			break
+			coinBalance := utils.RawToDecimal(b, decoder.wm.Decimal())
+			if coinBalance.LessThan(fixFees) {
+				if feesShortAddr == nil {
+					feesShortAddr = addr
+					feesShortBal = coinBalance
+				}
+				continue
+			}
 		}
+
+		from = addr
+		found = true
+		decoder.markSelectedAddress(accountID, addresses, addr)
+		decoder.invalidateAssetsBalances(addr.Address)
+		break
 	}
 
-	//判断余额是否足够支付发送数额+手续费
-	if balance.LessThan(computeTotalSend) {
-		return fmt.Errorf("The balance: %s is not enough! ", balance.StringFixed(decoder.wm.Decimal()))
+	if !found {
+		//代币余额足够，主币不足支付手续费
+		if feesShortAddr != nil {
+			feesSupport := getFeesSupportParam(rawTx)
+			if feesSupport == nil {
+				return openwallet.Errorf(openwallet.ErrInsufficientFees, "address [%s] balance: %s is not enough to pay fees: %s ", feesShortAddr.Address, feesShortBal.StringFixed(decoder.wm.Decimal()), fixFees.StringFixed(decoder.wm.Decimal()))
+			}
+			return decoder.createFeesSupportForTransfer(wrapper, rawTx, feesSupport, feesShortAddr.Address, fixFees)
+		}
+		//账户合计余额足够，但没有单个地址足够支付，提示使用归集计划
+		consolidate := ""
+		if !accountTotal.LessThan(computeTotalSend) {
+			consolidate = fmt.Sprintf("account balance: %s is spread over addresses, use CreateNSGConsolidatedRawTransaction to consolidate them first ", accountTotal.String())
+		}
+		if isToken {
+			return openwallet.Errorf(openwallet.ErrInsufficientTokenBalanceOfAddress, "The token balance: %s is not enough! %s", balance.String(), consolidate)
+		}
+		return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "The balance: %s is not enough! %s", balance.StringFixed(decoder.wm.Decimal()), consolidate)
 	}
 
 	rawTx.FeeRate = fixFees.StringFixed(decoder.wm.Decimal())
@@ -231,7 +311,12 @@ func (decoder *TransactionDecoder) CreateNSGRawTransaction(wrapper openwallet.Wa
 	decoder.wm.Log.Std.Notice("Receive: %v", totalSend.String())
 	decoder.wm.Log.Std.Notice("-----------------------------------------------")
 
-	err = decoder.createNSGRawTransaction(wrapper, rawTx, from, target, totalSend.Shift(precision), totalSend.String(), fixFees)
+	rawSend, err := utils.ToRawAmount(totalSend, precision)
+	if err != nil {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "send amount: %v", err)
+	}
+
+	err = decoder.createNSGRawTransaction(wrapper, rawTx, from, target, rawSend, totalSend.String(), fixFees)
 	if err != nil {
 		return err
 	}
@@ -331,27 +416,59 @@ func (decoder *TransactionDecoder) VerifyNSGRawTransaction(wrapper openwallet.Wa
 
 //GetRawTransactionFeeRate 获取交易单的费率
 func (decoder *TransactionDecoder) GetRawTransactionFeeRate() (feeRate string, unit string, err error) {
-	return decoder.wm.Config.FixFees, "TX", nil
+	fees, err := decoder.wm.GetTransactionFee(rpc.TxType_NSG)
+	if err != nil {
+		return "", "", err
+	}
+	return fees.StringFixed(decoder.wm.Decimal()), "TX", nil
 }
 
 //CreateNSGSummaryRawTransaction 创建汇总交易
+//并发查询地址余额，查询失败的地址以带错误的交易单返回，不中断其他地址的汇总
+//代币地址主币不足时，从手续费支持账户余额足够的地址轮流补充，并返回依赖补充交易的代币汇总交易单，依赖关系见扩展参数fundingPlan
+//每个交易单的扩展参数summaryCursor记录下一页开始位置和需重试的地址，调用方保存后通过sumRawTx的扩展参数summaryCursor传回以继续
+//没有创建交易单时可用GetSummaryCursor取得进度，sumRawTx的扩展参数resume为true时采用本进程内记录的进度
 func (decoder *TransactionDecoder) CreateNSGSummaryRawTransaction(wrapper openwallet.WalletDAI, sumRawTx *openwallet.SummaryRawTransaction) ([]*openwallet.RawTransactionWithError, error) {
 
 	var (
 		accountID          = sumRawTx.Account.AccountID
-		minTransfer, _     = decimal.NewFromString(sumRawTx.MinTransfer)
-		addrBalanceArray   = make([]*openwallet.Balance, 0)
-		sumAddresses       = make([]*openwallet.Balance, 0)
+		minTransfer        = decimal.Zero
 		rawTxArray         = make([]*openwallet.RawTransactionWithError, 0)
 		target             = sumRawTx.SummaryAddress
 		fixFees            = decimal.New(0, 0)
-		precision          = int32(0)
-		addrMap            = make(map[string]*openwallet.Address)
+		precision          = decoder.wm.Decimal()
+		isToken            = sumRawTx.Coin.IsContract
 		feesSupportAccount *openwallet.AssetsAccount
 		feesAddresses      []*openwallet.Address
+		feesPool           *feesSupportPool
+		err                error
 	)
 
-	addresses, err := wrapper.GetAddressList(sumRawTx.AddressStartIndex, sumRawTx.AddressLimit, "AccountID", sumRawTx.Account.AccountID)
+	if isToken {
+		if err := decoder.wm.ContractDecoder.CheckContract(sumRawTx.Coin.Contract); err != nil {
+			return nil, err
+		}
+		precision = int32(sumRawTx.Coin.Contract.Decimals)
+
+		// 如果有提供手续费账户，检查账户是否存在
+		if feesAcount := sumRawTx.FeesSupportAccount; feesAcount != nil {
+			feesSupportAccount, feesAddresses, err = decoder.getFeesSupportAccount(wrapper, feesAcount)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, openwallet.Errorf(openwallet.ErrAccountNotAddress, "fees support account have not config")
+		}
+	}
+
+	if len(sumRawTx.MinTransfer) > 0 {
+		minTransfer, err = utils.ParseAmount(sumRawTx.MinTransfer, precision)
+		if err != nil {
+			return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "min transfer: %v", err)
+		}
+	}
+
+	addresses, cursor, err := decoder.getSummaryAddresses(wrapper, sumRawTx)
 	if err != nil {
 		return nil, err
 	}
@@ -360,217 +477,159 @@ func (decoder *TransactionDecoder) CreateNSGSummaryRawTransaction(wrapper openwa
 		return nil, fmt.Errorf("[%s] have not addresses", accountID)
 	}
 
-	searchAddrs := make([]string, 0)
-	for _, address := range addresses {
-		searchAddrs = append(searchAddrs, address.Address)
-		addrMap[address.Address] = address
-	}
-
 	//取得费率
 	if len(sumRawTx.FeeRate) == 0 {
-		fixFees, err = decimal.NewFromString(decoder.wm.Config.FixFees)
+		txType := uint32(rpc.TxType_NSG)
+		if isToken {
+			txType = rpc.TxType_Asset
+		}
+		fixFees, err = decoder.wm.GetTransactionFee(txType)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		fixFees, _ = decimal.NewFromString(sumRawTx.FeeRate)
+		fixFees, err = utils.ParseAmount(sumRawTx.FeeRate, decoder.wm.Decimal())
+		if err != nil {
+			return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "fee rate: %v", err)
+		}
 	}
 
-	if !sumRawTx.Coin.IsContract && decimal.Zero.Equal(minTransfer) {
+	if !isToken && decimal.Zero.Equal(minTransfer) {
 		minTransfer = fixFees
 	}
 
-	fixFees = fixFees.Shift(decoder.wm.Decimal())
+	//以下余额和数额均为链上整数
+	feesShow := fixFees
+	fixFees, err = utils.ToRawAmount(fixFees, decoder.wm.Decimal())
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "fees: %v", err)
+	}
+	minTransfer, err = utils.ToRawAmount(minTransfer, precision)
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "min transfer: %v", err)
+	}
 
-	if !sumRawTx.Coin.IsContract {
-		minTransfer = minTransfer.Shift(decoder.wm.Decimal())
-		addrBalanceArray, err = decoder.wm.Blockscanner.GetBalanceByAddress(searchAddrs...)
+	//手续费支持数量
+	var supportAmount decimal.Decimal
+	if isToken {
+		supportAmount, err = feesSupportAmount(sumRawTx.FeesSupportAccount, fixFees, decoder.wm.Decimal())
 		if err != nil {
-			return nil, err
+			return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "fees support amount: %v", err)
 		}
-		precision = decoder.wm.Decimal()
+	}
 
-		for _, addrBalance := range addrBalanceArray {
-			decoder.wm.Log.Debugf("addrBalance: %+v", addrBalance)
-			//检查余额是否超过最低转账
-			addrBalanceDec, _ := decimal.NewFromString(addrBalance.Balance)
-			addrBalanceDec = addrBalanceDec.Shift(decoder.wm.Decimal())
-			if addrBalanceDec.GreaterThanOrEqual(minTransfer) {
-				//添加到转账地址数组
-				sumAddresses = append(sumAddresses, addrBalance)
-			}
+	planID := newFundingPlanID(accountID)
+
+	//并发查询余额
+	balances := decoder.collectSummaryBalances(addresses, sumRawTx.Coin)
+
+	for _, b := range balances {
+		if b.err != nil {
+			cursor.FailedAddresses = append(cursor.FailedAddresses, b.address.Address)
 		}
+	}
+	decoder.saveSummaryCursor(sumRawTx, cursor)
 
-	} else {
-		minTransfer = minTransfer.Shift(int32(sumRawTx.Coin.Contract.Decimals))
-		// 代币转账
-		for _, address := range searchAddrs {
-			balance, balanceERR := decoder.wm.WalletClient.Wallet.GetAssetsBalance(address, sumRawTx.Coin.Contract.Address)
-			if balanceERR != nil {
-				decoder.wm.Log.Notice("GetAssetsBalance Error: [%+v], %+v", address, balanceERR)
-				continue
-			}
-			if balance == nil {
-				decoder.wm.Log.Notice("Address asset balance is nil: [%+v]", address)
-				continue
-			}
-			//addrBalanceArray = append(addrBalanceArray, &openwallet.Balance{
-			//	Address: address,
-			//	Balance: balance.Balance,
-			//})
-			precision = int32(balance.Precision)
-
-			addrBalanceDec, _ := decimal.NewFromString(balance.Balance)
-			if addrBalanceDec.GreaterThanOrEqual(minTransfer) {
-				//添加到转账地址数组
-				sumAddresses = append(sumAddresses, &openwallet.Balance{
-					Address: address,
-					Balance: balance.Balance,
-				})
-			}
+	appendResult := func(rawTx *openwallet.RawTransaction, createErr error) {
+		rawTx.SetExtParam("summaryCursor", cursor)
+		rawTxArray = append(rawTxArray, &openwallet.RawTransactionWithError{
+			RawTx: rawTx,
+			Error: openwallet.ConvertError(createErr),
+		})
+	}
+
+	//创建地址向汇总地址转账的交易单
+	createSweep := func(addr *summaryBalance) (*openwallet.RawTransaction, error) {
+		sumAmount := addr.balance
+		if !isToken {
+			sumAmount = sumAmount.Sub(fixFees)
 		}
+		decoder.wm.Log.Debugf("fees: %v", fixFees)
+		decoder.wm.Log.Debugf("sumAmount: %v", sumAmount)
 
-		// 如果有提供手续费账户，检查账户是否存在
-		if feesAcount := sumRawTx.FeesSupportAccount; feesAcount != nil {
-			account, supportErr := wrapper.GetAssetsAccountInfo(feesAcount.AccountID)
-			if supportErr != nil {
-				return nil, openwallet.Errorf(openwallet.ErrAccountNotFound, "can not find fees support account")
-			}
-			feesSupportAccount = account
+		rawTx := &openwallet.RawTransaction{
+			Coin:     sumRawTx.Coin,
+			Account:  sumRawTx.Account,
+			FeeRate:  sumRawTx.FeeRate,
+			Fees:     feesShow.StringFixed(decoder.wm.Decimal()),
+			Required: 1,
+		}
 
-			//获取手续费支持账户的地址nonce
-			getFeesAddresses, feesSupportErr := wrapper.GetAddressList(0, 1,
-				"AccountID", feesSupportAccount.AccountID)
-			if feesSupportErr != nil {
-				return nil, openwallet.NewError(openwallet.ErrAddressNotFound, "fees support account have not addresses")
-			}
-			feesAddresses = getFeesAddresses
+		txAmount, err := utils.FormatAmount(sumAmount, precision)
+		if err != nil {
+			rawTx.TxFrom = []string{addr.address.Address}
+			return rawTx, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "sum amount: %v", err)
+		}
+		rawTx.To = map[string]string{
+			sumRawTx.SummaryAddress: txAmount,
+		}
+
+		return rawTx, decoder.createNSGRawTransaction(wrapper, rawTx, addr.address, target, sumAmount, txAmount, feesShow)
+	}
 
-			if len(feesAddresses) == 0 {
-				return nil, openwallet.Errorf(openwallet.ErrAccountNotAddress, "fees support account have not addresses")
+	for _, addr := range balances {
+
+		//查询余额失败的地址，返回带错误的交易单
+		if addr.err != nil {
+			decoder.wm.Log.Notice("get balance of address [%s] failed: %v", addr.address.Address, addr.err)
+			rawTx := &openwallet.RawTransaction{
+				Coin:    sumRawTx.Coin,
+				Account: sumRawTx.Account,
+				TxFrom:  []string{addr.address.Address},
 			}
-		} else {
-			return nil, openwallet.Errorf(openwallet.ErrAccountNotAddress, "fees support account have not config")
+			appendResult(rawTx, openwallet.Errorf(openwallet.ErrCallFullNodeAPIFailed, "get balance of address [%s] failed: %v", addr.address.Address, addr.err))
+			continue
 		}
-	}
 
-	if len(sumAddresses) == 0 {
-		return nil, nil
-	}
+		//检查余额是否超过最低转账
+		if addr.balance.LessThan(minTransfer) || decimal.Zero.GreaterThanOrEqual(addr.balance) {
+			continue
+		}
 
-	for _, addr := range sumAddresses {
+		//地址将转出，下次汇总需重新查询余额
+		decoder.invalidateAssetsBalances(addr.address.Address)
 
 		//判断主币余额是否够手续费
-		if sumRawTx.Coin.IsContract {
+		if isToken && addr.coinBalance.Cmp(fixFees) < 0 {
 
-			b, err := decoder.wm.WalletClient.Wallet.GetBalance(addr.Address)
-			if err != nil {
+			//通过手续费账户创建交易单
+			if feesPool == nil {
+				feesPool = decoder.newFeesSupportPool(feesAddresses)
+			}
+
+			//创建一笔交易单
+			rawTx := &openwallet.RawTransaction{}
+			source, createTxErr := allocateFeesSupport(feesPool, feesSupportAccount, supportAmount, fixFees)
+			if createTxErr != nil {
+				rawTx.Coin = sumRawTx.Coin
+				rawTx.Account = sumRawTx.Account
+				rawTx.TxFrom = []string{addr.address.Address}
+				appendResult(rawTx, createTxErr)
 				continue
 			}
-			coinBalance := decimal.New(int64(b), 0)
-			//decoder.wm.Log.Debugf("coinBalance: %s, fixFees: %s", coinBalance.String(), fixFees.String())
-			//主币余额不足
-			if coinBalance.Cmp(fixFees) < 0 {
-
-				//有手续费账户支持
-				if feesSupportAccount != nil {
-
-					//通过手续费账户创建交易单
-					supportAddress := addr.Address
-					supportAmount := decimal.Zero
-					feesSupportScale, _ := decimal.NewFromString(sumRawTx.FeesSupportAccount.FeesSupportScale)
-					fixSupportAmount, _ := decimal.NewFromString(sumRawTx.FeesSupportAccount.FixSupportAmount)
-
-					//优先采用固定支持数量
-					if fixSupportAmount.GreaterThan(decimal.Zero) {
-						supportAmount = fixSupportAmount
-					} else {
-						//没有固定支持数量，有手续费倍率，计算支持数量
-						if feesSupportScale.GreaterThan(decimal.Zero) {
-							supportAmount = feesSupportScale.Mul(fixFees)
-						} else {
-							//默认支持数量为手续费
-							supportAmount = fixFees
-						}
-					}
-
-					decoder.wm.Log.Debugf("create transaction for fees support account")
-					decoder.wm.Log.Debugf("fees account: %s", feesSupportAccount.AccountID)
-					decoder.wm.Log.Debugf("mini support amount: %s", fixFees.String())
-					decoder.wm.Log.Debugf("allow support amount: %s", supportAmount.String())
-					decoder.wm.Log.Debugf("support address: %s", supportAddress)
-
-					supportCoin := openwallet.Coin{
-						Symbol:     sumRawTx.Coin.Symbol,
-						IsContract: false,
-					}
-					sendAmount := supportAmount.Shift(-decoder.wm.Decimal())
-					//创建一笔交易单
-					rawTx := &openwallet.RawTransaction{
-						Coin:    supportCoin,
-						Account: feesSupportAccount,
-						To: map[string]string{
-							addr.Address: sendAmount.String(),
-						},
-						Required: 1,
-					}
-
-					if len(feesAddresses) == 0 {
-						decoder.wm.Log.Debugf("feesAddresses is empty")
-						continue
-					}
-					from := feesAddresses[0]
-
-					createTxErr := decoder.createNSGRawTransaction(wrapper, rawTx, from, addr.Address, supportAmount, sendAmount.String(), fixFees.Shift(-decoder.wm.Decimal()))
-					rawTxWithErr := &openwallet.RawTransactionWithError{
-						RawTx: rawTx,
-						Error: openwallet.ConvertError(createTxErr),
-					}
-
-					//创建成功，添加到队列
-					rawTxArray = append(rawTxArray, rawTxWithErr)
-
-					//汇总下一个
-					continue
-				}
+			createTxErr = decoder.createFeesSupportRawTransaction(wrapper, rawTx, feesSupportAccount, source.address, addr.address.Address, supportAmount, fixFees)
+			supportPlan := newFundingPlanParam(planID, FundingRoleFeesSupport, addr.address.Address)
+			rawTx.SetExtParam("fundingPlan", supportPlan)
+			appendResult(rawTx, createTxErr)
+			if createTxErr != nil {
+				continue
 			}
-		}
-
-		sumAmount, _ := decimal.NewFromString(addr.Balance)
 
-		if decimal.Zero.GreaterThanOrEqual(sumAmount) {
+			//补充交易确认后才能广播的代币汇总
+			sweepTx, sweepErr := createSweep(addr)
+			sweepTx.SetExtParam("fundingPlan", newFundingPlanParam(planID, FundingRoleSweep, addr.address.Address, supportPlan.ID))
+			appendResult(sweepTx, sweepErr)
 			continue
 		}
 
-		if !sumRawTx.Coin.IsContract {
-			sumAmount = sumAmount.Shift(decoder.wm.Decimal()).Sub(fixFees)
-		}
-		txAmount := sumAmount.Shift(-precision).StringFixed(precision)
-		decoder.wm.Log.Debugf("fees: %v", fixFees)
-		decoder.wm.Log.Debugf("sumAmount: %v", sumAmount)
-
-		//创建一笔交易单
-		rawTx := &openwallet.RawTransaction{
-			Coin:    sumRawTx.Coin,
-			Account: sumRawTx.Account,
-			FeeRate: sumRawTx.FeeRate,
-			To: map[string]string{
-				sumRawTx.SummaryAddress: txAmount,
-			},
-			Fees:     fixFees.Shift(-decoder.wm.Decimal()).StringFixed(decoder.wm.Decimal()),
-			Required: 1,
-		}
-
-		from := addrMap[addr.Address]
-		createErr := decoder.createNSGRawTransaction(wrapper, rawTx, from, target, sumAmount, txAmount, fixFees.Shift(-decoder.wm.Decimal()))
-		rawTxWithErr := &openwallet.RawTransactionWithError{
-			RawTx: rawTx,
-			Error: openwallet.ConvertError(createErr),
-		}
+		rawTx, createErr := createSweep(addr)
 
 		//创建成功，添加到队列
-		rawTxArray = append(rawTxArray, rawTxWithErr)
+		appendResult(rawTx, createErr)
+	}
 
+	if len(rawTxArray) == 0 {
+		return nil, nil
 	}
 
 	return rawTxArray, nil
@@ -595,19 +654,39 @@ func (decoder *TransactionDecoder) createNSGRawTransaction(
 	trx.Transaction = &rpc.Transaction{}
 	trx.Asset = &rpc.Asset{}
 	trx.Asset.UiaTransfer = &rpc.UiaTransfer{}
+	var err error
 	if rawTx.Coin.IsContract {
 		trx.Asset.UiaTransfer.Currency = rawTx.Coin.Contract.Address
-		trx.Asset.UiaTransfer.Amount = amount.String()
+		trx.Asset.UiaTransfer.Amount, err = utils.FormatRawAmount(amount)
 		trx.Type = rpc.TxType_Asset
 	} else {
-		trx.Amount = uint64(amount.IntPart())
+		trx.Amount, err = utils.RawToUint64(amount)
 		trx.Type = rpc.TxType_NSG
 	}
-	trx.Timestamp = utils.GetEpochTime() - 5 //这5应该可以配置吧？
+	if err != nil {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "send amount: %v", err)
+	}
+	rawFees, err := utils.ToRawAmount(fees, decoder.wm.Decimal())
+	if err == nil {
+		trx.Fee, err = utils.RawToUint64(rawFees)
+	}
+	if err != nil {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "fees: %v", err)
+	}
+	if err := trx.CheckAmount(); err != nil {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "%v", err)
+	}
+	timestamp, err := decoder.wm.GetTxTimestamp(rawTx)
+	if err != nil {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "%v", err)
+	}
+	trx.Timestamp = timestamp
 	trx.SenderPublicKey = from.PublicKey
 	trx.RecipientId = to
-	trx.Message = rawTx.GetExtParam().Get("memo").String()
-	trx.Fee = uint64(fees.Shift(decoder.wm.Decimal()).IntPart())
+	trx.Message, err = decoder.getTxMessage(rawTx)
+	if err != nil {
+		return err
+	}
 
 	//trx.ID = trx.GetID()
 	txBytes, err := json.Marshal(trx)
@@ -0,0 +1,305 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/nasgo-adapter/utils"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//归集计划中交易单的角色
+const (
+	FundingRoleSweep       = "sweep"       //把地址余额归集到付款地址或汇总地址
+	FundingRolePayout      = "payout"      //付款地址向目标地址转账
+	FundingRoleFeesSupport = "feesSupport" //手续费支持账户向地址补充主币
+)
+
+//FundingPlanParam 归集计划中交易单的扩展参数fundingPlan
+//交易单以ID相互引用，不依赖在返回数组中的位置，过滤掉出错的交易单后依然有效
+type FundingPlanParam struct {
+	PlanID          string   `json:"planID"` //同一次创建的交易单共用的计划标识
+	ID              string   `json:"id"`     //交易单在计划中的标识，角色:地址
+	Role            string   `json:"role"`
+	DependsOn       []string `json:"dependsOn,omitempty"`       //需要先被节点接受的交易单ID
+	FailedAddresses []string `json:"failedAddresses,omitempty"` //付款交易单记录查询余额失败、未参与归集的地址
+}
+
+//newFundingPlanID 创建计划标识，区分同一账户多次创建的计划
+func newFundingPlanID(accountID string) string {
+	return fmt.Sprintf("%s_%d", accountID, time.Now().UnixNano())
+}
+
+//newFundingPlanParam 计划中交易单的扩展参数，ID由角色和地址组成
+func newFundingPlanParam(planID, role, address string, dependsOn ...string) *FundingPlanParam {
+	return &FundingPlanParam{
+		PlanID:    planID,
+		ID:        role + ":" + address,
+		Role:      role,
+		DependsOn: dependsOn,
+	}
+}
+
+//fundingSource 账户地址的余额，链上整数
+type fundingSource struct {
+	address     *openwallet.Address
+	balance     decimal.Decimal //转账币种余额
+	coinBalance decimal.Decimal //主币余额，用于支付手续费
+}
+
+//fundingSweep 归集转账
+type fundingSweep struct {
+	source *fundingSource
+	amount decimal.Decimal
+}
+
+//CreateNSGConsolidatedRawTransaction 账户内单个地址余额不足时，创建归集计划
+//返回的数组先是其他地址归集到付款地址的交易单，最后是付款地址向目标地址的转账，即rawTx本身
+//扩展参数fundingPlan记录依赖关系，付款交易单需在归集交易单被节点接受后广播
+func (decoder *TransactionDecoder) CreateNSGConsolidatedRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) ([]*openwallet.RawTransactionWithError, error) {
+
+	var (
+		totalSend = decimal.Zero
+		target    = ""
+		accountID = rawTx.Account.AccountID
+		isToken   = rawTx.Coin.IsContract
+		precision = decoder.wm.Decimal()
+		fixFees   decimal.Decimal
+		err       error
+	)
+
+	if len(rawTx.To) == 0 {
+		return nil, errors.New("Receiver address is empty")
+	}
+
+	if isToken {
+		if err := decoder.wm.ContractDecoder.CheckContract(rawTx.Coin.Contract); err != nil {
+			return nil, err
+		}
+		precision = int32(rawTx.Coin.Contract.Decimals)
+	}
+
+	addresses, err := decoder.getAccountAddresses(wrapper, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	for address, amount := range rawTx.To {
+		amt, err := utils.ParseAmount(amount, precision)
+		if err != nil {
+			return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "send amount to [%s]: %v", address, err)
+		}
+		target = address
+		totalSend = totalSend.Add(amt)
+	}
+
+	if len(rawTx.FeeRate) == 0 {
+		txType := uint32(rpc.TxType_NSG)
+		if isToken {
+			txType = rpc.TxType_Asset
+		}
+		fixFees, err = decoder.wm.GetTransactionFee(txType)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		fixFees, err = utils.ParseAmount(rawTx.FeeRate, decoder.wm.Decimal())
+		if err != nil {
+			return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "fee rate: %v", err)
+		}
+	}
+
+	sources, failedAddresses := decoder.getFundingSources(addresses, rawTx.Coin)
+
+	rawSend, err := utils.ToRawAmount(totalSend, precision)
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "send amount: %v", err)
+	}
+	rawFees, err := utils.ToRawAmount(fixFees, decoder.wm.Decimal())
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "fees: %v", err)
+	}
+
+	payout, sweeps, err := planFunding(sources, rawSend, rawFees, isToken)
+	if err != nil {
+		if len(failedAddresses) > 0 {
+			owErr := openwallet.ConvertError(err)
+			return nil, openwallet.Errorf(owErr.Code(), "%s; get balance of addresses %v failed", owErr.Error(), failedAddresses)
+		}
+		return nil, err
+	}
+
+	//参与归集的地址余额将变化，清除缓存
+	decoder.invalidateAssetsBalances(payout.address.Address)
+	for _, sweep := range sweeps {
+		decoder.invalidateAssetsBalances(sweep.source.address.Address)
+	}
+
+	decoder.wm.Log.Std.Notice("-----------------------------------------------")
+	decoder.wm.Log.Std.Notice("From Account: %s", accountID)
+	decoder.wm.Log.Std.Notice("Payout Address: %s", payout.address.Address)
+	decoder.wm.Log.Std.Notice("To Address: %s", target)
+	decoder.wm.Log.Std.Notice("Sweep Transactions: %d", len(sweeps))
+	decoder.wm.Log.Std.Notice("Fees: %v", fixFees.String())
+	decoder.wm.Log.Std.Notice("Receive: %v", totalSend.String())
+	decoder.wm.Log.Std.Notice("-----------------------------------------------")
+
+	feeRate := fixFees.StringFixed(decoder.wm.Decimal())
+	rawTxArray := make([]*openwallet.RawTransactionWithError, 0, len(sweeps)+1)
+	dependsOn := make([]string, 0, len(sweeps))
+	sweepFailed := false
+	planID := newFundingPlanID(accountID)
+
+	for _, sweep := range sweeps {
+		sweepTx := &openwallet.RawTransaction{
+			Coin:     rawTx.Coin,
+			Account:  rawTx.Account,
+			FeeRate:  feeRate,
+			Fees:     feeRate,
+			Required: 1,
+		}
+		sweepPlan := newFundingPlanParam(planID, FundingRoleSweep, sweep.source.address.Address)
+		sweepTx.SetExtParam("fundingPlan", sweepPlan)
+
+		txAmount, createErr := utils.FormatAmount(sweep.amount, precision)
+		if createErr != nil {
+			createErr = openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "sweep amount: %v", createErr)
+		} else {
+			sweepTx.To = map[string]string{
+				payout.address.Address: txAmount,
+			}
+			createErr = decoder.createNSGRawTransaction(wrapper, sweepTx, sweep.source.address, payout.address.Address, sweep.amount, txAmount, fixFees)
+		}
+		if createErr != nil {
+			sweepFailed = true
+		}
+		rawTxArray = append(rawTxArray, &openwallet.RawTransactionWithError{
+			RawTx: sweepTx,
+			Error: openwallet.ConvertError(createErr),
+		})
+		dependsOn = append(dependsOn, sweepPlan.ID)
+	}
+
+	rawTx.FeeRate = feeRate
+	rawTx.Fees = feeRate
+	payoutPlan := newFundingPlanParam(planID, FundingRolePayout, payout.address.Address, dependsOn...)
+	if len(failedAddresses) > 0 {
+		payoutPlan.FailedAddresses = failedAddresses
+	}
+	rawTx.SetExtParam("fundingPlan", payoutPlan)
+
+	var payoutErr error
+	if sweepFailed {
+		payoutErr = openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "sweep transaction of funding plan create failed")
+	} else {
+		payoutErr = decoder.createNSGRawTransaction(wrapper, rawTx, payout.address, target, rawSend, totalSend.String(), fixFees)
+	}
+	rawTxArray = append(rawTxArray, &openwallet.RawTransactionWithError{
+		RawTx: rawTx,
+		Error: openwallet.ConvertError(payoutErr),
+	})
+
+	return rawTxArray, nil
+}
+
+//getFundingSources 并发查询账户地址的转账币种余额和主币余额，查询失败的地址不参与归集，返回其地址
+func (decoder *TransactionDecoder) getFundingSources(addresses []*openwallet.Address, coin openwallet.Coin) ([]*fundingSource, []string) {
+
+	sources := make([]*fundingSource, 0, len(addresses))
+	failed := make([]string, 0)
+	for _, b := range decoder.collectSummaryBalances(addresses, coin) {
+		if b.err != nil {
+			decoder.wm.Log.Notice("get balance of funding address [%s] failed: %v", b.address.Address, b.err)
+			failed = append(failed, b.address.Address)
+			continue
+		}
+		if b.balance.GreaterThan(decimal.Zero) {
+			sources = append(sources, &fundingSource{
+				address:     b.address,
+				balance:     b.balance,
+				coinBalance: b.coinBalance,
+			})
+		}
+	}
+
+	return sources, failed
+}
+
+//planFunding 选择余额最大且能支付手续费的地址为付款地址，其余地址按余额从大到小归集，直至足够支付amount
+//amount和fees为链上整数，主币转账时付款地址需额外支付手续费，每笔归集都需由归集地址支付手续费
+func planFunding(sources []*fundingSource, amount, fees decimal.Decimal, isToken bool) (*fundingSource, []*fundingSweep, error) {
+
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].balance.GreaterThan(sources[j].balance)
+	})
+
+	//地址可转出的数量
+	spendable := func(source *fundingSource) decimal.Decimal {
+		if source.coinBalance.LessThan(fees) {
+			return decimal.Zero
+		}
+		if isToken {
+			return source.balance
+		}
+		return source.balance.Sub(fees)
+	}
+
+	var payout *fundingSource
+	for _, source := range sources {
+		if spendable(source).GreaterThan(decimal.Zero) {
+			payout = source
+			break
+		}
+	}
+
+	if payout == nil {
+		return nil, nil, openwallet.Errorf(openwallet.ErrInsufficientFees, "no address of account has enough balance to pay fees")
+	}
+
+	shortfall := amount.Sub(spendable(payout))
+	sweeps := make([]*fundingSweep, 0)
+
+	for _, source := range sources {
+		if !shortfall.GreaterThan(decimal.Zero) {
+			break
+		}
+		if source == payout {
+			continue
+		}
+		available := spendable(source)
+		if !available.GreaterThan(decimal.Zero) {
+			continue
+		}
+		sweepAmount := decimal.Min(available, shortfall)
+		sweeps = append(sweeps, &fundingSweep{source: source, amount: sweepAmount})
+		shortfall = shortfall.Sub(sweepAmount)
+	}
+
+	if shortfall.GreaterThan(decimal.Zero) {
+		if isToken {
+			return nil, nil, openwallet.Errorf(openwallet.ErrInsufficientTokenBalanceOfAddress, "the token balance of account is not enough, lack: %s", shortfall.String())
+		}
+		return nil, nil, openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "the balance of account is not enough, lack: %s", shortfall.String())
+	}
+
+	return payout, sweeps, nil
+}
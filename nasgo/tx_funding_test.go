@@ -0,0 +1,202 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+func newFundingSource(address string, balance, coinBalance int64) *fundingSource {
+	return &fundingSource{
+		address:     &openwallet.Address{Address: address},
+		balance:     decimal.New(balance, 0),
+		coinBalance: decimal.New(coinBalance, 0),
+	}
+}
+
+func TestPlanFunding(t *testing.T) {
+	fees := decimal.New(10, 0)
+
+	//主币：A可转出90，B可转出40，C可转出20
+	sources := []*fundingSource{
+		newFundingSource("C", 30, 30),
+		newFundingSource("A", 100, 100),
+		newFundingSource("B", 50, 50),
+	}
+	payout, sweeps, err := planFunding(sources, decimal.New(120, 0), fees, false)
+	if err != nil {
+		t.Fatalf("planFunding() error = %v", err)
+	}
+	if payout.address.Address != "A" || len(sweeps) != 1 || sweeps[0].source.address.Address != "B" || !sweeps[0].amount.Equal(decimal.New(30, 0)) {
+		t.Errorf("planFunding() payout = %s, sweeps = %d", payout.address.Address, len(sweeps))
+	}
+
+	_, _, err = planFunding(sources, decimal.New(151, 0), fees, false)
+	if err == nil {
+		t.Errorf("planFunding() want insufficient balance error")
+	}
+
+	//代币：A没有主币支付手续费，不参与
+	sources = []*fundingSource{
+		newFundingSource("A", 100, 0),
+		newFundingSource("B", 50, 10),
+		newFundingSource("C", 30, 20),
+	}
+	payout, sweeps, err = planFunding(sources, decimal.New(80, 0), fees, true)
+	if err != nil {
+		t.Fatalf("planFunding() error = %v", err)
+	}
+	if payout.address.Address != "B" || len(sweeps) != 1 || !sweeps[0].amount.Equal(decimal.New(30, 0)) {
+		t.Errorf("planFunding() payout = %s, sweeps = %d", payout.address.Address, len(sweeps))
+	}
+
+	//单个地址足够时不需要归集
+	payout, sweeps, err = planFunding(sources, decimal.New(40, 0), fees, true)
+	if err != nil || payout.address.Address != "B" || len(sweeps) != 0 {
+		t.Errorf("planFunding() payout = %v, sweeps = %d, err = %v", payout, len(sweeps), err)
+	}
+}
+
+func TestNewFundingPlanParam(t *testing.T) {
+	planID := newFundingPlanID("account")
+	if planID == newFundingPlanID("other") {
+		t.Errorf("plan id of different accounts should differ")
+	}
+
+	support := newFundingPlanParam(planID, FundingRoleFeesSupport, "A")
+	sweep := newFundingPlanParam(planID, FundingRoleSweep, "A", support.ID)
+
+	//依赖以ID引用，过滤掉其他交易单后仍能找到
+	rawTxs := []*openwallet.RawTransaction{{}, {}}
+	rawTxs[0].SetExtParam("fundingPlan", sweep)
+	rawTxs[1].SetExtParam("fundingPlan", support)
+
+	plans := make(map[string]*FundingPlanParam)
+	for _, rawTx := range rawTxs {
+		var plan FundingPlanParam
+		if err := json.Unmarshal([]byte(rawTx.GetExtParam().Get("fundingPlan").Raw), &plan); err != nil {
+			t.Fatalf("decode fundingPlan failed: %v", err)
+		}
+		if plan.PlanID != planID {
+			t.Errorf("planID = %s, want %s", plan.PlanID, planID)
+		}
+		plans[plan.ID] = &plan
+	}
+
+	got := plans["sweep:A"]
+	if got == nil || len(got.DependsOn) != 1 {
+		t.Fatalf("sweep plan = %+v", got)
+	}
+	if dep := plans[got.DependsOn[0]]; dep == nil || dep.Role != FundingRoleFeesSupport {
+		t.Errorf("sweep depends on %v, want fees support of A", got.DependsOn)
+	}
+}
+
+func TestTransactionDecoder_GetFundingSources(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/accounts/getBalance" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.URL.Query().Get("address") {
+		case "A":
+			fmt.Fprint(w, `{"success":true,"balance":300}`)
+		case "B":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "C":
+			fmt.Fprint(w, `{"success":true,"balance":0}`)
+		default:
+			fmt.Fprint(w, `{"success":true,"balance":100}`)
+		}
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.WalletClient = rpc.NewClient(server.URL)
+	decoder := wm.TxDecoder.(*TransactionDecoder)
+
+	addresses := []*openwallet.Address{{Address: "A"}, {Address: "B"}, {Address: "C"}, {Address: "D"}}
+	sources, failed := decoder.getFundingSources(addresses, openwallet.Coin{Symbol: "NSG"})
+
+	//B查询失败被跳过并返回，C余额为0不参与归集
+	if len(failed) != 1 || failed[0] != "B" {
+		t.Errorf("failed addresses = %v, want [B]", failed)
+	}
+	if len(sources) != 2 || sources[0].address.Address != "A" || sources[1].address.Address != "D" {
+		t.Fatalf("sources = %v, want A, D", sources)
+	}
+	if sources[0].balance.String() != "300" || sources[0].coinBalance.String() != "300" {
+		t.Errorf("source A balance = %s, coin balance = %s", sources[0].balance, sources[0].coinBalance)
+	}
+}
+
+func TestTransactionDecoder_CreateNSGRawTransaction_SuggestConsolidation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/accounts/getBalance" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"success":true,"balance":60000000}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		addresses []string
+		want      bool
+	}{
+		{name: "account balance is enough", addresses: []string{"A", "B"}, want: true},
+		{name: "account balance is not enough", addresses: []string{"A"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm := NewWalletManager()
+			wm.WalletClient = rpc.NewClient(server.URL)
+			wm.Config.FixFees = "0.1"
+			decoder := wm.TxDecoder.(*TransactionDecoder)
+
+			wrapper := &summaryTestWallet{}
+			for _, a := range tt.addresses {
+				wrapper.addresses = append(wrapper.addresses, &openwallet.Address{Address: a, AccountID: "account"})
+			}
+			rawTx := &openwallet.RawTransaction{
+				Coin:    openwallet.Coin{Symbol: "NSG"},
+				Account: &openwallet.AssetsAccount{AccountID: "account"},
+				To:      map[string]string{"T": "1"},
+			}
+
+			err := decoder.CreateNSGRawTransaction(wrapper, rawTx)
+			if err == nil {
+				t.Fatalf("CreateNSGRawTransaction() want insufficient balance error")
+			}
+			//单个地址不足但账户合计足够时，提示使用归集计划
+			if got := strings.Contains(err.Error(), "CreateNSGConsolidatedRawTransaction"); got != tt.want {
+				t.Errorf("CreateNSGRawTransaction() error = %v, suggest consolidation = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+}
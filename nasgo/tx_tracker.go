@@ -0,0 +1,252 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+const (
+	periodOfTxTrack = 10 * time.Second //交易单追踪间隔
+)
+
+//交易单追踪状态
+const (
+	TxTrackStatusPending   = "pending"   //等待打包
+	TxTrackStatusConfirmed = "confirmed" //已打包
+	TxTrackStatusFailed    = "failed"    //节点拒绝
+	TxTrackStatusDropped   = "dropped"   //超出有效期未打包
+)
+
+//TxTrackResult 交易单追踪结果
+type TxTrackResult struct {
+	TxID        string
+	Status      string
+	BlockHeight uint64
+	BlockHash   string
+	Reason      string
+	Transaction *openwallet.Transaction //广播时返回的交易记录
+}
+
+//TxTrackNotificationObject 交易单追踪被通知对象
+//扫描器的观测者实现了该接口，也会收到通知
+type TxTrackNotificationObject interface {
+
+	//TxTrackNotify 交易单状态变化通知
+	TxTrackNotify(result *TxTrackResult) error
+}
+
+type trackedTx struct {
+	param     map[string]interface{} //广播参数，重发时使用
+	timestamp int64                  //交易单时间戳
+	result    *TxTrackResult
+}
+
+//TxTracker 已广播交易单的确认追踪
+type TxTracker struct {
+	wm           *WalletManager
+	mu           sync.Mutex
+	txs          map[string]*trackedTx
+	observers    map[TxTrackNotificationObject]bool
+	running      bool
+	PeriodOfTask time.Duration
+}
+
+//NewTxTracker 交易单追踪器
+func NewTxTracker(wm *WalletManager) *TxTracker {
+	tracker := TxTracker{}
+	tracker.wm = wm
+	tracker.txs = make(map[string]*trackedTx)
+	tracker.observers = make(map[TxTrackNotificationObject]bool)
+	tracker.PeriodOfTask = periodOfTxTrack
+	return &tracker
+}
+
+//AddObserver 添加观测者
+func (tracker *TxTracker) AddObserver(obj TxTrackNotificationObject) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if obj == nil {
+		return
+	}
+	tracker.observers[obj] = true
+}
+
+//RemoveObserver 移除观测者
+func (tracker *TxTracker) RemoveObserver(obj TxTrackNotificationObject) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	delete(tracker.observers, obj)
+}
+
+//Track 追踪已广播的交易单，重复追踪同一txid会被忽略
+func (tracker *TxTracker) Track(param map[string]interface{}, timestamp int64, tx *openwallet.Transaction) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if _, exist := tracker.txs[tx.TxID]; exist {
+		return
+	}
+
+	tracker.txs[tx.TxID] = &trackedTx{
+		param:     param,
+		timestamp: timestamp,
+		result: &TxTrackResult{
+			TxID:        tx.TxID,
+			Status:      TxTrackStatusPending,
+			Transaction: tx,
+		},
+	}
+
+	if !tracker.running {
+		tracker.running = true
+		go tracker.run()
+	}
+}
+
+//GetTrackResult 查询正在追踪的交易单状态
+func (tracker *TxTracker) GetTrackResult(txid string) (*TxTrackResult, bool) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	item, ok := tracker.txs[txid]
+	if !ok {
+		return nil, false
+	}
+	result := *item.result
+	return &result, true
+}
+
+//run 有待确认交易单时运行，全部完成后退出
+func (tracker *TxTracker) run() {
+	ticker := time.NewTicker(tracker.PeriodOfTask)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		tracker.mu.Lock()
+		pending := make([]*trackedTx, 0, len(tracker.txs))
+		for _, item := range tracker.txs {
+			pending = append(pending, item)
+		}
+		if len(pending) == 0 {
+			tracker.running = false
+			tracker.mu.Unlock()
+			return
+		}
+		tracker.mu.Unlock()
+
+		for _, item := range pending {
+			if tracker.checkTx(item) {
+				tracker.mu.Lock()
+				delete(tracker.txs, item.result.TxID)
+				tracker.mu.Unlock()
+				tracker.notify(item.result)
+			}
+		}
+	}
+}
+
+//checkTx 检查交易单状态，返回是否已结束追踪
+func (tracker *TxTracker) checkTx(item *trackedTx) bool {
+
+	txid := item.result.TxID
+	client := tracker.wm.WalletClient
+
+	trx, err := client.Tx.GetTransactionByID(txid)
+	if err != nil {
+		tracker.wm.Log.Std.Info("tx tracker can not get transaction [%s]; unexpected error: %v", txid, err)
+		return false
+	}
+
+	if trx != nil {
+		height, _ := strconv.ParseUint(trx.Height, 10, 64)
+		tracker.mu.Lock()
+		item.result.Status = TxTrackStatusConfirmed
+		item.result.BlockHeight = height
+		item.result.BlockHash = trx.BlockID
+		item.result.Reason = ""
+		tracker.mu.Unlock()
+		return true
+	}
+
+	unconfirmed, err := client.Tx.GetUnconfirmedTransaction(txid)
+	if err != nil {
+		tracker.wm.Log.Std.Info("tx tracker can not get unconfirmed transaction [%s]; unexpected error: %v", txid, err)
+		return false
+	}
+
+	if unconfirmed != nil {
+		return false
+	}
+
+	//超出有效期，节点不会再接受该交易单
+	if tracker.wm.Config.GetEpochTime() > item.timestamp+tracker.wm.Config.TxValidWindow {
+		tracker.mu.Lock()
+		item.result.Status = TxTrackStatusDropped
+		item.result.Reason = "transaction is expired and not packed"
+		tracker.mu.Unlock()
+		return true
+	}
+
+	//交易单不在节点中，有效期内重新广播，txid不变，不会重复打包
+	tracker.wm.Log.Std.Info("tx tracker resubmit transaction [%s]", txid)
+	result, err := client.Tx.Broadcast(item.param, 1)
+	if err != nil {
+		tracker.wm.Log.Std.Info("tx tracker resubmit transaction [%s] failed; unexpected error: %v", txid, err)
+		//节点明确拒绝时立即结束追踪，节点繁忙则等待下次重发
+		if result != nil && !result.Transient() {
+			tracker.mu.Lock()
+			item.result.Status = TxTrackStatusFailed
+			item.result.Reason = err.Error()
+			tracker.mu.Unlock()
+			return true
+		}
+	}
+
+	return false
+}
+
+//notify 通知观测者
+func (tracker *TxTracker) notify(result *TxTrackResult) {
+
+	observers := make([]TxTrackNotificationObject, 0)
+
+	tracker.mu.Lock()
+	for o := range tracker.observers {
+		observers = append(observers, o)
+	}
+	tracker.mu.Unlock()
+
+	tracker.wm.Blockscanner.Mu.RLock()
+	for o := range tracker.wm.Blockscanner.Observers {
+		if obj, ok := o.(TxTrackNotificationObject); ok {
+			observers = append(observers, obj)
+		}
+	}
+	tracker.wm.Blockscanner.Mu.RUnlock()
+
+	tracker.wm.Log.Std.Info("tx tracker transaction [%s] %s, height: %d", result.TxID, result.Status, result.BlockHeight)
+
+	for _, o := range observers {
+		err := o.TxTrackNotify(result)
+		if err != nil {
+			tracker.wm.Log.Std.Error("TxTrackNotify unexpected error: %v", err)
+		}
+	}
+}
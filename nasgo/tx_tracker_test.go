@@ -0,0 +1,236 @@
+/*
+ * Copyright 2020 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package nasgo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocktree/nasgo-adapter/rpc"
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//trackTestNode 模拟节点中交易单的状态
+type trackTestNode struct {
+	mu           sync.Mutex
+	confirmed    map[string]bool
+	unconfirmed  map[string]bool
+	broadcastErr string //非空时节点拒绝广播
+	broadcasts   int
+	*httptest.Server
+}
+
+func newTrackTestNode() *trackTestNode {
+	node := &trackTestNode{
+		confirmed:   make(map[string]bool),
+		unconfirmed: make(map[string]bool),
+	}
+	node.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		node.mu.Lock()
+		defer node.mu.Unlock()
+		id := r.URL.Query().Get("id")
+		switch r.URL.Path {
+		case "/api/transactions/get":
+			if node.confirmed[id] {
+				fmt.Fprintf(w, `{"success":true,"transaction":{"id":"%s","height":"7","blockId":"block7"}}`, id)
+				return
+			}
+			fmt.Fprint(w, `{"success":false,"error":"Transaction not found"}`)
+		case "/api/transactions/unconfirmed/get":
+			if node.unconfirmed[id] {
+				fmt.Fprintf(w, `{"success":true,"transaction":{"id":"%s"}}`, id)
+				return
+			}
+			fmt.Fprint(w, `{"success":false,"error":"Transaction not found"}`)
+		case "/peer/transactions":
+			node.broadcasts++
+			if len(node.broadcastErr) > 0 {
+				fmt.Fprintf(w, `{"success":false,"error":"%s"}`, node.broadcastErr)
+				return
+			}
+			node.unconfirmed["tx1"] = true
+			fmt.Fprint(w, `{"success":true,"transactionId":"tx1"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return node
+}
+
+func (node *trackTestNode) set(fn func(node *trackTestNode)) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	fn(node)
+}
+
+func (node *trackTestNode) broadcastCount() int {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	return node.broadcasts
+}
+
+//trackRecorder 记录交易单追踪通知
+type trackRecorder struct {
+	results chan *TxTrackResult
+}
+
+func newTrackRecorder() *trackRecorder {
+	return &trackRecorder{results: make(chan *TxTrackResult, 4)}
+}
+
+func (r *trackRecorder) TxTrackNotify(result *TxTrackResult) error {
+	r.results <- result
+	return nil
+}
+
+func (r *trackRecorder) wait(t *testing.T) *TxTrackResult {
+	t.Helper()
+	select {
+	case result := <-r.results:
+		return result
+	case <-time.After(3 * time.Second):
+		t.Fatalf("tx tracker did not notify")
+	}
+	return nil
+}
+
+//trackScanObserver 扫描器观测者，同时实现交易单追踪通知
+type trackScanObserver struct {
+	*trackRecorder
+}
+
+func (o *trackScanObserver) BlockScanNotify(header *openwallet.BlockHeader) error {
+	return nil
+}
+
+func (o *trackScanObserver) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+	return nil
+}
+
+func (o *trackScanObserver) BlockExtractSmartContractDataNotify(sourceKey string, data *openwallet.SmartContractReceipt) error {
+	return nil
+}
+
+func newTrackTestTracker(serverURL string) (*TxTracker, *trackRecorder) {
+	wm := NewWalletManager()
+	wm.WalletClient = rpc.NewClient(serverURL)
+	tracker := wm.TxTracker
+	tracker.PeriodOfTask = 10 * time.Millisecond
+	recorder := newTrackRecorder()
+	tracker.AddObserver(recorder)
+	return tracker, recorder
+}
+
+func TestTxTracker_Confirmed(t *testing.T) {
+	node := newTrackTestNode()
+	defer node.Close()
+	node.set(func(node *trackTestNode) { node.confirmed["tx1"] = true })
+
+	tracker, recorder := newTrackTestTracker(node.URL)
+	scanObserver := &trackScanObserver{newTrackRecorder()}
+	tracker.wm.Blockscanner.AddObserver(scanObserver)
+
+	tracker.Track(map[string]interface{}{}, tracker.wm.Config.GetEpochTime(), &openwallet.Transaction{TxID: "tx1"})
+
+	result := recorder.wait(t)
+	if result.TxID != "tx1" || result.Status != TxTrackStatusConfirmed || result.BlockHeight != 7 || result.BlockHash != "block7" {
+		t.Errorf("track result = %+v, want confirmed at 7 block7", result)
+	}
+
+	//扫描器的观测者也收到通知
+	if result := scanObserver.wait(t); result.Status != TxTrackStatusConfirmed {
+		t.Errorf("scan observer result = %+v, want confirmed", result)
+	}
+
+	if _, ok := tracker.GetTrackResult("tx1"); ok {
+		t.Errorf("confirmed transaction is still tracked")
+	}
+	if node.broadcastCount() != 0 {
+		t.Errorf("confirmed transaction is resubmitted")
+	}
+}
+
+func TestTxTracker_Failed(t *testing.T) {
+	node := newTrackTestNode()
+	defer node.Close()
+	node.set(func(node *trackTestNode) { node.broadcastErr = "Insufficient balance" })
+
+	tracker, recorder := newTrackTestTracker(node.URL)
+	tracker.Track(map[string]interface{}{}, tracker.wm.Config.GetEpochTime(), &openwallet.Transaction{TxID: "tx1"})
+
+	//节点明确拒绝，不等到有效期结束
+	result := recorder.wait(t)
+	if result.Status != TxTrackStatusFailed || len(result.Reason) == 0 {
+		t.Errorf("track result = %+v, want failed with reason", result)
+	}
+	if node.broadcastCount() != 1 {
+		t.Errorf("broadcasts = %d, want 1", node.broadcastCount())
+	}
+}
+
+func TestTxTracker_Dropped(t *testing.T) {
+	node := newTrackTestNode()
+	defer node.Close()
+
+	tracker, recorder := newTrackTestTracker(node.URL)
+	expired := tracker.wm.Config.GetEpochTime() - tracker.wm.Config.TxValidWindow - 10
+	tracker.Track(map[string]interface{}{}, expired, &openwallet.Transaction{TxID: "tx1"})
+
+	result := recorder.wait(t)
+	if result.Status != TxTrackStatusDropped || len(result.Reason) == 0 {
+		t.Errorf("track result = %+v, want dropped with reason", result)
+	}
+	if node.broadcastCount() != 0 {
+		t.Errorf("expired transaction is resubmitted")
+	}
+}
+
+func TestTxTracker_ResubmitUnconfirmed(t *testing.T) {
+	node := newTrackTestNode()
+	defer node.Close()
+
+	tracker, recorder := newTrackTestTracker(node.URL)
+	tracker.Track(map[string]interface{}{}, tracker.wm.Config.GetEpochTime(), &openwallet.Transaction{TxID: "tx1"})
+
+	//不在节点中则重发，重发后进入交易池，不再重复广播
+	deadline := time.Now().Add(3 * time.Second)
+	for node.broadcastCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if count := node.broadcastCount(); count != 1 {
+		t.Fatalf("broadcasts = %d, want 1", count)
+	}
+
+	result, ok := tracker.GetTrackResult("tx1")
+	if !ok || result.Status != TxTrackStatusPending {
+		t.Fatalf("track result = %+v, want pending", result)
+	}
+
+	node.set(func(node *trackTestNode) {
+		delete(node.unconfirmed, "tx1")
+		node.confirmed["tx1"] = true
+	})
+
+	result = recorder.wait(t)
+	if result.Status != TxTrackStatusConfirmed || result.BlockHeight != 7 {
+		t.Errorf("track result = %+v, want confirmed at 7", result)
+	}
+}
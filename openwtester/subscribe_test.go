@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/astaxie/beego/config"
+	"github.com/blocktree/nasgo-adapter/nasgo"
 	"github.com/blocktree/openwallet/v2/common/file"
 	"github.com/blocktree/openwallet/v2/log"
 	"github.com/blocktree/openwallet/v2/openw"
@@ -60,6 +61,12 @@ func (sub *subscriberSingle) BlockExtractSmartContractDataNotify(sourceKey strin
 	return nil
 }
 
+//TxTrackNotify 已广播交易单状态通知
+func (sub *subscriberSingle) TxTrackNotify(result *nasgo.TxTrackResult) error {
+	log.Std.Notice("tx: %s, status: %s, height: %d, reason: %s", result.TxID, result.Status, result.BlockHeight, result.Reason)
+	return nil
+}
+
 func TestSubscribeAddress(t *testing.T) {
 
 	var (
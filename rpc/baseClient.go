@@ -13,13 +13,22 @@ type ErrorResponse struct {
 	ErrorText  string `json:"error,omitempty"` // application-level error message, for debugging
 }
 
+const (
+	DefaultMagic   = "594fe0f3" // mainnet magic
+	DefaultVersion = "''"       // peer version header
+)
+
 type BaseClient struct {
 	baseAddress string
+	magic       string // network magic header of peer api
+	version     string // version header of peer api
 }
 
 func newBaseClient(baseAddress string) *BaseClient {
 	return &BaseClient{
 		baseAddress: baseAddress,
+		magic:       DefaultMagic,
+		version:     DefaultVersion,
 	}
 }
 
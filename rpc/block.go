@@ -94,3 +94,96 @@ func (blk *Block) GetByHeight(height uint64) (*Header, error) {
 	}
 	return blockResponse.Block, nil
 }
+
+type BlockStatusResponse struct {
+	Success   bool   `json:"success"`
+	Height    uint64 `json:"height"`
+	Fee       uint64 `json:"fee"`
+	Milestone uint64 `json:"milestone"`
+	Reward    uint64 `json:"reward"`
+	Supply    uint64 `json:"supply"`
+}
+
+// GetStatus get the status of chain
+func (blk *Block) GetStatus() (*BlockStatusResponse, error) {
+	resp, err := resty.
+		R().
+		Get(blk.bk.baseAddress + "/api/blocks/getStatus")
+	if err != nil {
+		return nil, err
+	}
+	body, err := blk.bk.ReadResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	statusResp := BlockStatusResponse{}
+	if err := json.Unmarshal(body, &statusResp); err != nil {
+		return nil, errors.New(err)
+	}
+	if !statusResp.Success {
+		return nil, errors.New("get block status failed")
+	}
+	return &statusResp, nil
+}
+
+type FeeResponse struct {
+	Success bool   `json:"success"`
+	Fee     uint64 `json:"fee"`
+}
+
+// FeeSchedule fees of each transaction type, raw amount
+type FeeSchedule struct {
+	Transfer uint64 // NSG transfer fee
+	Asset    uint64 // uia transfer fee
+	Vote     uint64 // vote fee
+	Delegate uint64 // register delegate fee
+}
+
+// GetFee get the transfer fee
+func (blk *Block) GetFee() (uint64, error) {
+	return blk.getFee("/api/blocks/getFee")
+}
+
+// GetFeeSchedule get the fees of transfer, uia transfer, vote and delegate
+func (blk *Block) GetFeeSchedule() (*FeeSchedule, error) {
+	transfer, err := blk.GetFee()
+	if err != nil {
+		return nil, err
+	}
+	vote, err := blk.getFee("/api/accounts/delegates/fee")
+	if err != nil {
+		return nil, err
+	}
+	delegate, err := blk.getFee("/api/delegates/fee")
+	if err != nil {
+		return nil, err
+	}
+	// uia transfer is charged as a normal transfer, node has no separate api for it
+	return &FeeSchedule{
+		Transfer: transfer,
+		Asset:    transfer,
+		Vote:     vote,
+		Delegate: delegate,
+	}, nil
+}
+
+func (blk *Block) getFee(path string) (uint64, error) {
+	resp, err := resty.
+		R().
+		Get(blk.bk.baseAddress + path)
+	if err != nil {
+		return 0, err
+	}
+	body, err := blk.bk.ReadResponse(resp)
+	if err != nil {
+		return 0, err
+	}
+	feeResp := FeeResponse{}
+	if err := json.Unmarshal(body, &feeResp); err != nil {
+		return 0, errors.New(err)
+	}
+	if !feeResp.Success {
+		return 0, errors.Errorf("get fee from %s failed", path)
+	}
+	return feeResp.Fee, nil
+}
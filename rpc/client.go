@@ -5,6 +5,8 @@ type Client struct {
 	Wallet      *Wallet
 	Tx          *Tx
 	Block       *Block
+	Uia         *Uia
+	Peer        *Peer
 	bk          *BaseClient
 }
 
@@ -12,9 +14,17 @@ func NewClient(baseAddress string) *Client {
 	bk := newBaseClient(baseAddress)
 	return &Client{
 		baseAddress: baseAddress,
-		bk:          newBaseClient(baseAddress),
+		bk:          bk,
 		Wallet:      newWalletClient(bk),
 		Tx:          newTxClient(bk),
 		Block:       newBlockClient(bk),
+		Uia:         newUiaClient(bk),
+		Peer:        newPeerClient(bk),
 	}
 }
+
+// SetNetwork set the magic and version headers of peer api
+func (c *Client) SetNetwork(magic, version string) {
+	c.bk.magic = magic
+	c.bk.version = version
+}
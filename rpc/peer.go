@@ -0,0 +1,142 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-errors/errors"
+	"gopkg.in/resty.v1"
+)
+
+type Peer struct {
+	bk *BaseClient
+}
+
+func newPeerClient(bk *BaseClient) *Peer {
+	return &Peer{
+		bk: bk,
+	}
+}
+
+type PeerVersionResponse struct {
+	Success bool   `json:"success"`
+	Version string `json:"version"`
+	Build   string `json:"build"`
+	Net     string `json:"net"`
+}
+
+type NethashResponse struct {
+	Success bool   `json:"success"`
+	Nethash string `json:"nethash"`
+}
+
+// GetVersion get the version of node
+func (p *Peer) GetVersion() (*PeerVersionResponse, error) {
+	resp, err := resty.
+		R().
+		Get(p.bk.baseAddress + "/api/peers/version")
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.bk.ReadResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	versionResp := PeerVersionResponse{}
+	if err := json.Unmarshal(body, &versionResp); err != nil {
+		return nil, errors.New(err)
+	}
+	return &versionResp, nil
+}
+
+// GetNethash get the network magic of node
+func (p *Peer) GetNethash() (string, error) {
+	resp, err := resty.
+		R().
+		Get(p.bk.baseAddress + "/api/blocks/getNethash")
+	if err != nil {
+		return "", err
+	}
+	body, err := p.bk.ReadResponse(resp)
+	if err != nil {
+		return "", err
+	}
+	nethashResp := NethashResponse{}
+	if err := json.Unmarshal(body, &nethashResp); err != nil {
+		return "", errors.New(err)
+	}
+	if !nethashResp.Success {
+		return "", errors.New("get nethash failed")
+	}
+	return nethashResp.Nethash, nil
+}
+
+type PeersResponse struct {
+	Success    bool `json:"success"`
+	TotalCount int  `json:"totalCount"`
+}
+
+type SyncStatusResponse struct {
+	Success     bool   `json:"success"`
+	Syncing     bool   `json:"syncing"`
+	BlocksCount uint64 `json:"blocksCount"`
+	Height      uint64 `json:"height"`
+}
+
+// GetPeerCount get the count of peers connected by node
+func (p *Peer) GetPeerCount() (int, error) {
+	resp, err := resty.
+		R().
+		Get(p.bk.baseAddress + "/api/peers?limit=1")
+	if err != nil {
+		return 0, err
+	}
+	body, err := p.bk.ReadResponse(resp)
+	if err != nil {
+		return 0, err
+	}
+	peersResp := PeersResponse{}
+	if err := json.Unmarshal(body, &peersResp); err != nil {
+		return 0, errors.New(err)
+	}
+	return peersResp.TotalCount, nil
+}
+
+// GetSyncStatus get the sync status of node
+func (p *Peer) GetSyncStatus() (*SyncStatusResponse, error) {
+	resp, err := resty.
+		R().
+		Get(p.bk.baseAddress + "/api/loader/status/sync")
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.bk.ReadResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	syncResp := SyncStatusResponse{}
+	if err := json.Unmarshal(body, &syncResp); err != nil {
+		return nil, errors.New(err)
+	}
+	return &syncResp, nil
+}
+
+// GetNodeTime get the clock of node by the Date header of response
+func (p *Peer) GetNodeTime() (time.Time, error) {
+	resp, err := resty.
+		R().
+		Get(p.bk.baseAddress + "/api/blocks/getHeight")
+	if err != nil {
+		return time.Time{}, err
+	}
+	date := resp.Header().Get("Date")
+	if len(date) == 0 {
+		return time.Time{}, errors.New("node response has no Date header")
+	}
+	t, err := http.ParseTime(date)
+	if err != nil {
+		return time.Time{}, errors.New(err)
+	}
+	return t, nil
+}
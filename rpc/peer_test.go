@@ -0,0 +1,27 @@
+package rpc
+
+import (
+	"testing"
+)
+
+func TestPeer_GetNethash(t *testing.T) {
+	client := NewClient(Url)
+	got, err := client.Peer.GetNethash()
+	if err != nil {
+		t.Errorf("Peer.GetNethash() error = %v", err)
+		return
+	}
+	if got != DefaultMagic {
+		t.Errorf("Peer.GetNethash() = %v, want %v", got, DefaultMagic)
+	}
+}
+
+func TestPeer_GetVersion(t *testing.T) {
+	client := NewClient(Url)
+	got, err := client.Peer.GetVersion()
+	if err != nil {
+		t.Errorf("Peer.GetVersion() error = %v", err)
+		return
+	}
+	t.Logf("version: %+v", got)
+}
@@ -3,11 +3,13 @@ package rpc
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/blocktree/nasgo-adapter/utils"
 	"github.com/blocktree/openwallet/v2/log"
-	"github.com/imroc/req"
-	"github.com/tidwall/gjson"
+	"github.com/shopspring/decimal"
 
 	"github.com/go-errors/errors"
 	"gopkg.in/resty.v1"
@@ -28,6 +30,34 @@ const (
 	TxType_Asset          = 14 //asset transactions
 )
 
+const (
+	MaxMessageLength          = 256 //max bytes of transaction message accepted by node
+	assetTransactionsPageSize = 100 //page size of uia transactions by block
+)
+
+var txTypeNames = map[uint32]string{
+	TxType_NSG:            "transfer",
+	TxType_SetSecureCode:  "setSecureCode",
+	TxType_Delegate:       "delegate",
+	TxType_Vote:           "vote",
+	TxType_MultiSig:       "multisignature",
+	TxType_PublishDAPP:    "dapp",
+	TxType_DeopsitDAPP:    "inTransfer",
+	TxType_WithdrawalDAPP: "outTransfer",
+	TxType_RegPublisher:   "uiaIssuer",
+	TxType_RegAsset:       "uiaAsset",
+	TxType_IssueAsset:     "uiaIssue",
+	TxType_Asset:          "uiaTransfer",
+}
+
+// TxTypeName the name of transaction type, unknown type returns "type_{n}"
+func TxTypeName(txType uint32) string {
+	if name, ok := txTypeNames[txType]; ok {
+		return name
+	}
+	return fmt.Sprintf("type_%d", txType)
+}
+
 type TxResponse struct {
 	Success     bool         `json:"success"`
 	Transaction *Transaction `json:"transaction"`
@@ -36,6 +66,7 @@ type TxResponse struct {
 type TxsResponse struct {
 	Success      bool           `json:"success"`
 	Transactions []*Transaction `json:"transactions"`
+	Count        int            `json:"count"`
 }
 
 type Transaction struct {
@@ -57,7 +88,76 @@ type Transaction struct {
 }
 
 type Asset struct {
-	UiaTransfer *UiaTransfer `json:"uiaTransfer,omitempty"`
+	UiaTransfer    *UiaTransfer    `json:"uiaTransfer,omitempty"`
+	Signature      *Signature      `json:"signature,omitempty"`
+	Delegate       *Delegate       `json:"delegate,omitempty"`
+	Vote           *Vote           `json:"vote,omitempty"`
+	Multisignature *Multisignature `json:"multisignature,omitempty"`
+	Dapp           *Dapp           `json:"dapp,omitempty"`
+	InTransfer     *DappTransfer   `json:"inTransfer,omitempty"`
+	OutTransfer    *DappTransfer   `json:"outTransfer,omitempty"`
+	UiaIssuer      *UiaIssuer      `json:"uiaIssuer,omitempty"`
+	UiaAsset       *UiaAsset       `json:"uiaAsset,omitempty"`
+	UiaIssue       *UiaIssue       `json:"uiaIssue,omitempty"`
+}
+
+// Signature asset of set secure code transaction
+type Signature struct {
+	PublicKey string `json:"publicKey"`
+}
+
+// Delegate asset of register delegate transaction
+type Delegate struct {
+	Username  string `json:"username"`
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+// Vote asset of vote transaction, "+publicKey" to vote and "-publicKey" to unvote
+type Vote struct {
+	Votes []string `json:"votes"`
+}
+
+// Multisignature asset of multisignature registration transaction
+type Multisignature struct {
+	Min       int      `json:"min"`
+	Lifetime  int      `json:"lifetime"`
+	Keysgroup []string `json:"keysgroup"`
+}
+
+// Dapp asset of publish dapp transaction
+type Dapp struct {
+	Name     string `json:"name"`
+	Category int    `json:"category,omitempty"`
+	Link     string `json:"link,omitempty"`
+}
+
+// DappTransfer asset of dapp deposit and withdrawal transaction
+type DappTransfer struct {
+	DappID        string `json:"dappId"`
+	TransactionID string `json:"transactionId,omitempty"`
+	Currency      string `json:"currency"`
+	Amount        string `json:"amount,omitempty"`
+}
+
+// UiaIssuer asset of register issuer transaction
+type UiaIssuer struct {
+	Name string `json:"name"`
+	Desc string `json:"desc,omitempty"`
+}
+
+// UiaAsset asset of register uia transaction
+type UiaAsset struct {
+	Name      string `json:"name"`
+	Desc      string `json:"desc,omitempty"`
+	Maximum   string `json:"maximum"`
+	Precision uint8  `json:"precision"`
+	Strategy  string `json:"strategy,omitempty"`
+}
+
+// UiaIssue asset of issue uia transaction
+type UiaIssue struct {
+	Currency string `json:"currency"`
+	Amount   string `json:"amount"`
 }
 
 type UiaTransfer struct {
@@ -67,6 +167,11 @@ type UiaTransfer struct {
 	Precision     uint8  `json:"precision,omitempty"`
 }
 
+// RawAmount raw amount of uia transfer
+func (t *UiaTransfer) RawAmount() (decimal.Decimal, error) {
+	return utils.ParseRawAmount(t.Amount)
+}
+
 type Tx struct {
 	bk *BaseClient
 }
@@ -85,10 +190,16 @@ func (tx *Tx) GetTransaction(id string) (*Transaction, error) {
 		return nil, errors.New(err)
 	}
 	body, err := tx.bk.ReadResponse(resp)
+	if err != nil {
+		return nil, err
+	}
 	response := TxsResponse{}
 	if err := json.Unmarshal(body, &response); err != nil {
 		return nil, errors.New(err)
 	}
+	if len(response.Transactions) == 0 {
+		return nil, errors.Errorf("transaction [%s] not found", id)
+	}
 	return response.Transactions[0], nil
 }
 
@@ -100,6 +211,9 @@ func (tx *Tx) GetTransactionsByBlock(blockId string) ([]*Transaction, error) {
 		return nil, errors.New(err)
 	}
 	body, err := tx.bk.ReadResponse(resp)
+	if err != nil {
+		return nil, err
+	}
 	response := TxsResponse{}
 	if err := json.Unmarshal(body, &response); err != nil {
 		return nil, errors.New(err)
@@ -107,66 +221,222 @@ func (tx *Tx) GetTransactionsByBlock(blockId string) ([]*Transaction, error) {
 	return response.Transactions, nil
 }
 
-type TxPublishResponse struct {
-	Result bool   `json:"success"`
-	Error  string `json:"error"`
+// GetAssetTransactionsByBlock get the uia transactions with asset detail in the block, paged until all are fetched
+func (tx *Tx) GetAssetTransactionsByBlock(blockId string) ([]*Transaction, error) {
+	transactions := make([]*Transaction, 0)
+	for offset := 0; ; offset += assetTransactionsPageSize {
+		resp, err := resty.
+			R().
+			SetQueryParam("blockId", blockId).
+			SetQueryParam("limit", strconv.Itoa(assetTransactionsPageSize)).
+			SetQueryParam("offset", strconv.Itoa(offset)).
+			Get(tx.bk.baseAddress + "/api/uia/transactions")
+		if err != nil {
+			return nil, errors.New(err)
+		}
+		body, err := tx.bk.ReadResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+		response := TxsResponse{}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, errors.New(err)
+		}
+		if !response.Success {
+			return nil, errors.Errorf("get uia transactions of block [%s] failed", blockId)
+		}
+		transactions = append(transactions, response.Transactions...)
+		if len(response.Transactions) < assetTransactionsPageSize || len(transactions) >= response.Count {
+			break
+		}
+	}
+	return transactions, nil
 }
 
-func (tx *Tx) Broadcast(txData interface{}) error {
+// GetTransactionByID get confirmed transaction by id, return nil if it is not packed in block
+func (tx *Tx) GetTransactionByID(id string) (*Transaction, error) {
+	return tx.getTransaction("/api/transactions/get?id=" + id)
+}
 
-	b, err := json.Marshal(txData)
-	if err != nil {
-		return err
-	}
-	log.Debugf("Broadcast tx: %s", string(b))
+// GetUnconfirmedTransaction get transaction in the pool by id, return nil if it is not in pool
+func (tx *Tx) GetUnconfirmedTransaction(id string) (*Transaction, error) {
+	return tx.getTransaction("/api/transactions/unconfirmed/get?id=" + id)
+}
+
+func (tx *Tx) getTransaction(path string) (*Transaction, error) {
 	resp, err := resty.
 		R().
-		SetBody(b).
-		SetHeader("Content-Type", "application/json").
-		SetHeader("version", "''").
-		SetHeader("magic", "594fe0f3").
-		Post(tx.bk.baseAddress + "/peer/transactions")
+		Get(tx.bk.baseAddress + path)
 	if err != nil {
-		return errors.New(err)
+		return nil, errors.New(err)
 	}
 	body, err := tx.bk.ReadResponse(resp)
 	if err != nil {
-		return err
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return nil, nil
+		}
+		return nil, err
 	}
-	response := TxPublishResponse{}
-	if err := json.Unmarshal(body, &response); err != nil || !response.Result {
-		if len(response.Error) > 0 {
-			return errors.New(response.Error)
+	response := struct {
+		TxResponse
+		Error string `json:"error"`
+	}{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, errors.New(err)
+	}
+	if !response.Success {
+		if strings.Contains(strings.ToLower(response.Error), "not found") {
+			return nil, nil
 		}
-		return errors.New(err)
+		return nil, errors.New(response.Error)
 	}
-	return nil
+	return response.Transaction, nil
+}
+
+type TxPublishResponse struct {
+	Result        bool   `json:"success"`
+	TransactionId string `json:"transactionId"`
+	Error         string `json:"error"`
 }
 
-func (tx *Tx) BroadcastTx(txData interface{}, try int64) error {
+// BroadcastStatus classified result of broadcast
+type BroadcastStatus int
 
-	err := fmt.Errorf("broadcast tx fails %v times", try)
+const (
+	BroadcastAccepted            BroadcastStatus = iota // accepted by node
+	BroadcastDuplicate                                  // already in pool or confirmed
+	BroadcastInsufficientBalance                        // balance is not enough
+	BroadcastInvalidSignature                           // signature verify failed
+	BroadcastTimestampOutOfRange                        // timestamp in future or expired
+	BroadcastNodeBusy                                   // node is unreachable, syncing or overloaded
+	BroadcastRejected                                   // other reasons
+)
+
+var broadcastStatusText = map[BroadcastStatus]string{
+	BroadcastAccepted:            "accepted",
+	BroadcastDuplicate:           "duplicate",
+	BroadcastInsufficientBalance: "insufficient balance",
+	BroadcastInvalidSignature:    "invalid signature",
+	BroadcastTimestampOutOfRange: "timestamp out of range",
+	BroadcastNodeBusy:            "node busy",
+	BroadcastRejected:            "rejected",
+}
 
-	for try > 0 {
-		try--
-		authHeader := req.Header{
-			"version":      "''",
-			"magic":        "594fe0f3",
-			"Content-Type": "application/json",
+func (s BroadcastStatus) String() string {
+	return broadcastStatusText[s]
+}
+
+// BroadcastResult result of broadcast
+type BroadcastResult struct {
+	Status  BroadcastStatus
+	TxID    string
+	Message string // message from node
+}
+
+// Success the transaction is accepted or has been accepted before
+func (r *BroadcastResult) Success() bool {
+	return r.Status == BroadcastAccepted || r.Status == BroadcastDuplicate
+}
+
+// Transient the failure may disappear by retry
+func (r *BroadcastResult) Transient() bool {
+	return r.Status == BroadcastNodeBusy
+}
+
+// reasons of node rejection, matched in lower case
+var broadcastErrorRules = []struct {
+	status   BroadcastStatus
+	keywords []string
+}{
+	{BroadcastDuplicate, []string{"already exists", "already confirmed", "already processed", "already in process", "already in pool"}},
+	{BroadcastInsufficientBalance, []string{"insufficient"}},
+	{BroadcastInvalidSignature, []string{"signature"}},
+	{BroadcastTimestampOutOfRange, []string{"timestamp", "expired"}},
+	{BroadcastNodeBusy, []string{"busy", "loading", "not ready", "syncing", "queue is full", "too many"}},
+}
+
+// classifyBroadcastError classify the error message from node
+func classifyBroadcastError(message string) BroadcastStatus {
+	msg := strings.ToLower(message)
+	for _, rule := range broadcastErrorRules {
+		for _, keyword := range rule.keywords {
+			if strings.Contains(msg, keyword) {
+				return rule.status
+			}
 		}
+	}
+	return BroadcastRejected
+}
 
-		r, err := req.Post(tx.bk.baseAddress+"/peer/transactions", req.BodyJSON(txData), authHeader)
-		if err != nil {
-			return errors.New(err)
+const (
+	broadcastBackoff    = 1 * time.Second
+	broadcastMaxBackoff = 16 * time.Second
+)
+
+// Broadcast post transaction to node, retry with backoff when node is busy.
+// error is returned when the transaction is not accepted, the result is always returned.
+func (tx *Tx) Broadcast(txData interface{}, try int64) (*BroadcastResult, error) {
+
+	if try <= 0 {
+		try = 1
+	}
+
+	b, err := json.Marshal(txData)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Broadcast tx: %s", string(b))
+
+	backoff := broadcastBackoff
+	result := &BroadcastResult{}
+	for i := int64(0); i < try; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			if backoff < broadcastMaxBackoff {
+				backoff *= 2
+			}
 		}
-		//log.Std.Info("%+v", r)
-		//log.Debugf("response: %s", r.String())
-		resp := gjson.ParseBytes(r.Bytes())
-		if !resp.Get("error").Exists() {
-			return nil
+
+		result = tx.broadcast(b)
+		if !result.Transient() {
+			break
 		}
-		//log.Std.Info("%+v", resp.Get("error").String())
-		time.Sleep(1 * time.Second)
+		log.Debugf("Broadcast tx retry %d, node busy: %s", i+1, result.Message)
+	}
+
+	if !result.Success() {
+		return result, errors.Errorf("broadcast tx failed, %s: %s", result.Status, result.Message)
+	}
+	return result, nil
+}
+
+func (tx *Tx) broadcast(body []byte) *BroadcastResult {
+	resp, err := resty.
+		R().
+		SetBody(body).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("version", tx.bk.version).
+		SetHeader("magic", tx.bk.magic).
+		Post(tx.bk.baseAddress + "/peer/transactions")
+	if err != nil {
+		return &BroadcastResult{Status: BroadcastNodeBusy, Message: err.Error()}
+	}
+
+	response := TxPublishResponse{}
+	if err := json.Unmarshal(resp.Body(), &response); err != nil {
+		if resp.StatusCode() >= 500 || resp.StatusCode() == 429 {
+			return &BroadcastResult{Status: BroadcastNodeBusy, Message: fmt.Sprintf("http status %d", resp.StatusCode())}
+		}
+		return &BroadcastResult{Status: BroadcastRejected, Message: fmt.Sprintf("http status %d, cannot read response: %s", resp.StatusCode(), string(resp.Body()))}
+	}
+
+	if response.Result && resp.StatusCode() == 200 {
+		return &BroadcastResult{Status: BroadcastAccepted, TxID: response.TransactionId}
+	}
+
+	status := classifyBroadcastError(response.Error)
+	if status == BroadcastRejected && resp.StatusCode() >= 500 {
+		status = BroadcastNodeBusy
 	}
-	return err
+	return &BroadcastResult{Status: status, Message: response.Error}
 }
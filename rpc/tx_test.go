@@ -3,7 +3,10 @@ package rpc
 import (
 	"encoding/json"
 	"github.com/blocktree/openwallet/v2/log"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strconv"
 	"testing"
 )
 
@@ -83,7 +86,7 @@ func TestTx_GetTransactions(t *testing.T) {
 	}
 }
 
-func TestTx_BroadcastTx(t *testing.T) {
+func TestTx_Broadcast(t *testing.T) {
 	rawTx := `
 {"transaction":{"type":0,"amount":123456,"fee":1000000,"recipientId":"NDt9qnAHnFAuP8T9GbzQ2o8UaacQscAcU2","message":"hello boy","timestamp":59049090,"asset":{},"senderPublicKey":"1a43612ad299bc749395ac164878044d3aee89cedc8fed7a08f13e3ad1b4fedc","signature":"1bb98abf922aae37175a980fcda371c1dcacb9aea5d82ae0903f4886b3d5a8423da0641872ea58407b8b47ca7ce3d82b2538791e127c6fc9534ed161e8b2a008","id":"edda385d1824b9f28d5f78dcd54c8e8d6004182de48b2302d892fb5adc427c88"}}
 `
@@ -95,10 +98,71 @@ func TestTx_BroadcastTx(t *testing.T) {
 	}
 
 	client := NewClient("http://localhost:20001")
-	err = client.Tx.BroadcastTx(tx, 1)
+	result, err := client.Tx.Broadcast(tx, 1)
 	if err != nil {
-		t.Errorf("json.Unmarshal error = %v", err)
+		t.Errorf("Tx.Broadcast() error = %v", err)
 		return
 	}
+	log.Infof("status: %s", result.Status)
 	//log.Infof("txid: %v", tx["id"])
 }
+
+func TestTx_GetAssetTransactionsByBlock(t *testing.T) {
+	client := NewClient(Url)
+	got, err := client.Tx.GetAssetTransactionsByBlock("b0938069b59f336482220a0128bf8b4874ed49792b354a2e74bafcd759a1bd15")
+	if err != nil {
+		t.Errorf("Tx.GetAssetTransactionsByBlock() error = %v", err)
+		return
+	}
+	for _, tx := range got {
+		log.Infof("tx: %+v, asset: %+v", tx, tx.Asset)
+	}
+}
+
+func TestClassifyBroadcastError(t *testing.T) {
+	tests := []struct {
+		message string
+		want    BroadcastStatus
+	}{
+		{message: "Transaction already confirmed: edda385d", want: BroadcastDuplicate},
+		{message: "Transaction already exists", want: BroadcastDuplicate},
+		{message: "Insufficient balance: NDt9qnAHnFAuP8T9GbzQ2o8UaacQscAcU2", want: BroadcastInsufficientBalance},
+		{message: "Failed to verify signature", want: BroadcastInvalidSignature},
+		{message: "Invalid transaction timestamp", want: BroadcastTimestampOutOfRange},
+		{message: "Blockchain is loading", want: BroadcastNodeBusy},
+		{message: "Invalid recipient", want: BroadcastRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			if got := classifyBroadcastError(tt.message); got != tt.want {
+				t.Errorf("classifyBroadcastError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTx_GetAssetTransactionsByBlock_Paging(t *testing.T) {
+	total := assetTransactionsPageSize + 5
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if r.URL.Query().Get("blockId") != "block" || limit != assetTransactionsPageSize {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		response := TxsResponse{Success: true, Count: total}
+		for i := offset; i < total && i < offset+limit; i++ {
+			response.Transactions = append(response.Transactions, &Transaction{ID: strconv.Itoa(i)})
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	got, err := NewClient(server.URL).Tx.GetAssetTransactionsByBlock("block")
+	if err != nil {
+		t.Fatalf("Tx.GetAssetTransactionsByBlock() error = %v", err)
+	}
+	if len(got) != total || got[total-1].ID != strconv.Itoa(total-1) {
+		t.Errorf("Tx.GetAssetTransactionsByBlock() count = %d, want %d", len(got), total)
+	}
+}
@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/go-errors/errors"
+	"gopkg.in/resty.v1"
+)
+
+type Uia struct {
+	bk *BaseClient
+}
+
+func newUiaClient(bk *BaseClient) *Uia {
+	return &Uia{
+		bk: bk,
+	}
+}
+
+type AssetInfoResponse struct {
+	Success bool       `json:"success"`
+	Asset   *AssetInfo `json:"asset"`
+}
+
+type AssetInfo struct {
+	Name         string `json:"name"`         // full name of asset, issuer.currency
+	Desc         string `json:"desc"`         // description
+	Maximum      string `json:"maximum"`      // max supply, raw amount
+	Precision    uint8  `json:"precision"`    // decimals
+	Strategy     string `json:"strategy"`     // issue strategy
+	Quantity     string `json:"quantity"`     // issued amount, raw amount
+	Height       uint64 `json:"height"`       // register height
+	IssuerID     string `json:"issuerId"`     // issuer address
+	MaximumShow  string `json:"maximumShow"`  // max supply with precision
+	QuantityShow string `json:"quantityShow"` // issued amount with precision
+}
+
+type IssuersResponse struct {
+	Success bool      `json:"success"`
+	Issuers []*Issuer `json:"issuers"`
+	Count   int       `json:"count"`
+}
+
+type Issuer struct {
+	Name     string `json:"name"`
+	Desc     string `json:"desc"`
+	IssuerID string `json:"issuerId"`
+}
+
+// GetAsset get uia asset info by name
+func (u *Uia) GetAsset(name string) (*AssetInfo, error) {
+	resp, err := resty.
+		R().
+		Get(u.bk.baseAddress + "/api/uia/assets/" + name)
+	if err != nil {
+		return nil, err
+	}
+	body, err := u.bk.ReadResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	assetResponse := AssetInfoResponse{}
+	if err := json.Unmarshal(body, &assetResponse); err != nil {
+		return nil, errors.New(err)
+	}
+	if assetResponse.Asset == nil {
+		return nil, errors.Errorf("asset [%s] not found", name)
+	}
+	return assetResponse.Asset, nil
+}
+
+// GetIssuers list uia issuers, return the issuers and total count
+func (u *Uia) GetIssuers(offset, limit int) ([]*Issuer, int, error) {
+	resp, err := resty.
+		R().
+		SetQueryParam("offset", strconv.Itoa(offset)).
+		SetQueryParam("limit", strconv.Itoa(limit)).
+		Get(u.bk.baseAddress + "/api/uia/issuers")
+	if err != nil {
+		return nil, 0, err
+	}
+	body, err := u.bk.ReadResponse(resp)
+	if err != nil {
+		return nil, 0, err
+	}
+	issuersResponse := IssuersResponse{}
+	if err := json.Unmarshal(body, &issuersResponse); err != nil {
+		return nil, 0, errors.New(err)
+	}
+	return issuersResponse.Issuers, issuersResponse.Count, nil
+}
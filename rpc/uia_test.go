@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"testing"
+)
+
+func TestUia_GetAsset(t *testing.T) {
+	type fields struct {
+		baseAddress string
+	}
+	type args struct {
+		name string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    uint8
+		wantErr bool
+	}{
+		{
+			name:   "test get asset",
+			fields: fields{baseAddress: Url},
+			args: args{
+				name: "IMM.IMM",
+			},
+			want:    8,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(tt.fields.baseAddress)
+			got, err := client.Uia.GetAsset(tt.args.name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Uia.GetAsset() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got.Precision != tt.want {
+				t.Errorf("Uia.GetAsset() precision = %v, want %v", got.Precision, tt.want)
+			}
+		})
+	}
+}
+
+func TestUia_GetIssuers(t *testing.T) {
+	client := NewClient(Url)
+	issuers, count, err := client.Uia.GetIssuers(0, 10)
+	if err != nil {
+		t.Errorf("Uia.GetIssuers() error = %v", err)
+		return
+	}
+	t.Logf("issuers count: %d", count)
+	for _, issuer := range issuers {
+		t.Logf("issuer: %+v", issuer)
+	}
+}
@@ -2,8 +2,12 @@ package rpc
 
 import (
 	"encoding/json"
+	"strconv"
 
+	"github.com/blocktree/nasgo-adapter/utils"
+	"github.com/blocktree/openwallet/v2/log"
 	"github.com/go-errors/errors"
+	"github.com/shopspring/decimal"
 	"gopkg.in/resty.v1"
 )
 
@@ -25,6 +29,24 @@ type AssetsBalance struct {
 	Precision uint8  `json:"precision"`
 }
 
+// RawBalance raw balance of asset, nil balance is zero
+func (b *AssetsBalance) RawBalance() (decimal.Decimal, error) {
+	if b == nil {
+		return decimal.Zero, nil
+	}
+	return utils.ParseRawAmount(b.Balance)
+}
+
+type AssetsBalanceListResponse struct {
+	Success  bool             `json:"success"`
+	Balances []*AssetsBalance `json:"balances"`
+	Count    int              `json:"count"`
+}
+
+const (
+	assetsBalancePageSize = 100
+)
+
 func newWalletClient(bk *BaseClient) *Wallet {
 	return &Wallet{
 		bk: bk,
@@ -64,5 +86,43 @@ func (w *Wallet) GetAssetsBalance(address, currency string) (*AssetsBalance, err
 	if err := json.Unmarshal(body, &balanceResponse); err != nil {
 		return nil, errors.New(err)
 	}
+	if _, err := balanceResponse.Balance.RawBalance(); err != nil {
+		return nil, errors.New(err)
+	}
 	return balanceResponse.Balance, nil
 }
+
+// GetAssetsBalanceList list all uia balances held by the address
+func (w *Wallet) GetAssetsBalanceList(address string) ([]*AssetsBalance, error) {
+	balances := make([]*AssetsBalance, 0)
+	for offset := 0; ; offset += assetsBalancePageSize {
+		resp, err := resty.
+			R().
+			SetQueryParam("limit", strconv.Itoa(assetsBalancePageSize)).
+			SetQueryParam("offset", strconv.Itoa(offset)).
+			Get(w.bk.baseAddress + "/api/uia/balances/" + address)
+		if err != nil {
+			return nil, err
+		}
+		body, err := w.bk.ReadResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+		listResponse := AssetsBalanceListResponse{}
+		if err := json.Unmarshal(body, &listResponse); err != nil {
+			return nil, errors.New(err)
+		}
+		//one malformed balance should not hide the other assets of the address
+		for _, balance := range listResponse.Balances {
+			if _, err := balance.RawBalance(); err != nil {
+				log.Warningf("skip uia balance %s of address %s: %v", balance.Currency, address, err)
+				continue
+			}
+			balances = append(balances, balance)
+		}
+		if len(listResponse.Balances) < assetsBalancePageSize || offset+len(listResponse.Balances) >= listResponse.Count {
+			break
+		}
+	}
+	return balances, nil
+}
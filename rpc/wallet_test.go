@@ -1,6 +1,9 @@
 package rpc
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 )
@@ -88,3 +91,55 @@ func TestWallet_GetAssetsBalance(t *testing.T) {
 		})
 	}
 }
+
+func TestWallet_GetAssetsBalanceList(t *testing.T) {
+	type fields struct {
+		baseAddress string
+	}
+	type args struct {
+		address string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			name:   "test assets balance list",
+			fields: fields{baseAddress: Url},
+			args: args{
+				address: "N2MzN3J9ZhHiWdmKxGSCxbwRHgWN7FzPC3",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(tt.fields.baseAddress)
+			got, err := client.Wallet.GetAssetsBalanceList(tt.args.address)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Wallet.GetAssetsBalanceList() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			for _, b := range got {
+				t.Logf("balance: %+v", b)
+			}
+		})
+	}
+}
+
+func TestWallet_GetAssetsBalanceList_SkipMalformed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success":true,"count":3,"balances":[{"currency":"NSG.CNY","balance":"500","precision":2},{"currency":"NSG.BAD","balance":"1.5","precision":2},{"currency":"NSG.USD","balance":"7","precision":2}]}`)
+	}))
+	defer server.Close()
+
+	got, err := NewClient(server.URL).Wallet.GetAssetsBalanceList("address")
+	if err != nil {
+		t.Fatalf("Wallet.GetAssetsBalanceList() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Currency != "NSG.CNY" || got[1].Currency != "NSG.USD" {
+		t.Errorf("Wallet.GetAssetsBalanceList() = %v, want NSG.CNY and NSG.USD", got)
+	}
+}
@@ -38,6 +38,10 @@ func (singer *TransactionSigner) VerifyAndCombineTransaction(emptyTrans string,
 		return false, "", errors.New("Invalid empty transaction data")
 	}
 
+	if err := trx.CheckAmount(); err != nil {
+		return false, "", err
+	}
+
 	ret := owcrypt.Verify(publickKey, nil, message, signature, owcrypt.ECC_CURVE_ED25519)
 	if ret != owcrypt.SUCCESS {
 		errinfo := fmt.Sprintf("verify error, ret:%v\n", "0x"+strconv.FormatUint(uint64(ret), 16))
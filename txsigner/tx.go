@@ -20,21 +20,43 @@ func (tx *Transaction) GetID() string {
 	return hex.EncodeToString(hash)
 }
 
+// CheckAmount check amount and fee fit the serialization of chain, and uia amount is a canonical integer string
+func (tx *Transaction) CheckAmount() error {
+	if _, err := utils.RawToUint64(utils.RawToDecimal(tx.Amount, 0)); err != nil {
+		return fmt.Errorf("transaction amount: %v", err)
+	}
+	if _, err := utils.RawToUint64(utils.RawToDecimal(tx.Fee, 0)); err != nil {
+		return fmt.Errorf("transaction fee: %v", err)
+	}
+	if tx.Type != rpc.TxType_Asset {
+		return nil
+	}
+	if tx.Asset == nil || tx.Asset.UiaTransfer == nil {
+		return fmt.Errorf("transaction asset is empty")
+	}
+	raw, err := tx.Asset.UiaTransfer.RawAmount()
+	if err != nil {
+		return fmt.Errorf("transaction uia amount: %v", err)
+	}
+	if amount, _ := utils.FormatRawAmount(raw); amount != tx.Asset.UiaTransfer.Amount {
+		return fmt.Errorf("transaction uia amount %q is not canonical, want %q", tx.Asset.UiaTransfer.Amount, amount)
+	}
+	return nil
+}
+
 func (tx *Transaction) GenerateHash(skipSignature bool) (hash []byte) {
 
+	//交易单为空或类型不支持时返回空hash
 	if tx == nil {
-		fmt.Errorf("transaction is empty")
 		return
 	}
 	if tx.Type != rpc.TxType_NSG && tx.Type != rpc.TxType_Asset {
-		fmt.Errorf("transaction type is not allowed: %v", tx.Type)
 		return
 	}
 
 	assetSlices := make([][]byte, 0)
 	if tx.Type == rpc.TxType_Asset {
 		if tx.Asset == nil || tx.Asset.UiaTransfer == nil {
-			fmt.Errorf("transaction asset is empty")
 			return
 		}
 		cur := []byte(tx.Asset.UiaTransfer.Currency)
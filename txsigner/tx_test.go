@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"github.com/blocktree/go-owcrypt"
 	"github.com/blocktree/openwallet/v2/log"
+	"math"
 	"reflect"
 	"testing"
 
@@ -63,3 +64,28 @@ func TestVerify(t *testing.T) {
 	}
 
 }
+
+func TestTransaction_CheckAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		tx      *rpc.Transaction
+		wantErr bool
+	}{
+		{name: "nsg", tx: &rpc.Transaction{Type: rpc.TxType_NSG, Amount: 12345678, Fee: 1000000}},
+		{name: "max amount", tx: &rpc.Transaction{Type: rpc.TxType_NSG, Amount: math.MaxInt64}},
+		{name: "amount overflow", tx: &rpc.Transaction{Type: rpc.TxType_NSG, Amount: math.MaxInt64 + 1}, wantErr: true},
+		{name: "fee overflow", tx: &rpc.Transaction{Type: rpc.TxType_NSG, Fee: math.MaxUint64}, wantErr: true},
+		{name: "uia", tx: &rpc.Transaction{Type: rpc.TxType_Asset, Asset: &rpc.Asset{UiaTransfer: &rpc.UiaTransfer{Currency: "NSG.CNY", Amount: "100000000000000000000000"}}}},
+		{name: "uia fraction", tx: &rpc.Transaction{Type: rpc.TxType_Asset, Asset: &rpc.Asset{UiaTransfer: &rpc.UiaTransfer{Currency: "NSG.CNY", Amount: "1.5"}}}, wantErr: true},
+		{name: "uia not canonical", tx: &rpc.Transaction{Type: rpc.TxType_Asset, Asset: &rpc.Asset{UiaTransfer: &rpc.UiaTransfer{Currency: "NSG.CNY", Amount: "0100"}}}, wantErr: true},
+		{name: "uia missing", tx: &rpc.Transaction{Type: rpc.TxType_Asset}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &Transaction{Transaction: tt.tx}
+			if err := tx.CheckAmount(); (err != nil) != tt.wantErr {
+				t.Errorf("Transaction.CheckAmount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
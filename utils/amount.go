@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// MaxRawAmount max raw amount of nsg amount and fee, the chain serializes them as signed 64-bit integer
+	MaxRawAmount = math.MaxInt64
+)
+
+var (
+	// ErrAmountInvalid amount is not a number
+	ErrAmountInvalid = errors.New("not a valid number")
+	// ErrAmountNegative amount is less than zero
+	ErrAmountNegative = errors.New("negative amount")
+	// ErrAmountPrecision amount has more decimal places than the precision
+	ErrAmountPrecision = errors.New("too many decimal places")
+	// ErrAmountOverflow amount is larger than MaxRawAmount
+	ErrAmountOverflow = errors.New("amount overflow")
+
+	maxRawAmount = decimal.New(MaxRawAmount, 0)
+)
+
+// AmountError amount conversion error, Reason is one of the ErrAmount errors
+type AmountError struct {
+	Value  string
+	Reason error
+}
+
+func (e *AmountError) Error() string {
+	return fmt.Sprintf("invalid amount %q: %v", e.Value, e.Reason)
+}
+
+// AmountErrorReason return the reason of amount error, nil if err is not an amount error
+func AmountErrorReason(err error) error {
+	if amountErr, ok := err.(*AmountError); ok {
+		return amountErr.Reason
+	}
+	return nil
+}
+
+func amountError(value string, reason error) error {
+	return &AmountError{Value: value, Reason: reason}
+}
+
+// RawToDecimal convert raw amount of chain to amount with decimals
+func RawToDecimal(raw uint64, decimals int32) decimal.Decimal {
+	return decimal.NewFromBigInt(new(big.Int).SetUint64(raw), -decimals)
+}
+
+// ParseRawAmount parse raw amount string, which must be a non-negative integer
+func ParseRawAmount(value string) (decimal.Decimal, error) {
+	raw, err := decimal.NewFromString(value)
+	if err != nil {
+		return decimal.Zero, amountError(value, ErrAmountInvalid)
+	}
+	if raw.Sign() < 0 {
+		return decimal.Zero, amountError(value, ErrAmountNegative)
+	}
+	if !raw.Equal(raw.Truncate(0)) {
+		return decimal.Zero, amountError(value, ErrAmountPrecision)
+	}
+	return raw, nil
+}
+
+// ParseAmount parse amount string with decimals, which must be non-negative and have at most decimals places
+func ParseAmount(value string, decimals int32) (decimal.Decimal, error) {
+	amount, err := decimal.NewFromString(value)
+	if err != nil {
+		return decimal.Zero, amountError(value, ErrAmountInvalid)
+	}
+	if _, err := ToRawAmount(amount, decimals); err != nil {
+		return decimal.Zero, amountError(value, AmountErrorReason(err))
+	}
+	return amount, nil
+}
+
+// ToRawAmount convert amount with decimals to raw amount of chain
+func ToRawAmount(amount decimal.Decimal, decimals int32) (decimal.Decimal, error) {
+	if amount.Sign() < 0 {
+		return decimal.Zero, amountError(amount.String(), ErrAmountNegative)
+	}
+	raw := amount.Shift(decimals)
+	if !raw.Equal(raw.Truncate(0)) {
+		return decimal.Zero, amountError(amount.String(), ErrAmountPrecision)
+	}
+	return raw.Truncate(0), nil
+}
+
+// RawToUint64 convert raw amount to uint64, which must not be larger than MaxRawAmount
+func RawToUint64(raw decimal.Decimal) (uint64, error) {
+	if _, err := ToRawAmount(raw, 0); err != nil {
+		return 0, err
+	}
+	if raw.GreaterThan(maxRawAmount) {
+		return 0, amountError(raw.String(), ErrAmountOverflow)
+	}
+	return uint64(raw.IntPart()), nil
+}
+
+// FormatRawAmount format raw amount as integer string
+func FormatRawAmount(raw decimal.Decimal) (string, error) {
+	raw, err := ToRawAmount(raw, 0)
+	if err != nil {
+		return "", err
+	}
+	return raw.StringFixed(0), nil
+}
+
+// FromRawAmount convert raw amount of chain to amount with decimals, raw must be a non-negative integer
+func FromRawAmount(raw decimal.Decimal, decimals int32) (decimal.Decimal, error) {
+	raw, err := ToRawAmount(raw, 0)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return raw.Shift(-decimals), nil
+}
+
+// FormatAmount format raw amount of chain as amount string with decimals places
+func FormatAmount(raw decimal.Decimal, decimals int32) (string, error) {
+	amount, err := FromRawAmount(raw, decimals)
+	if err != nil {
+		return "", err
+	}
+	return amount.StringFixed(decimals), nil
+}
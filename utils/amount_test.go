@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"testing/quick"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestRawToDecimal_Property(t *testing.T) {
+	roundTrip := func(raw uint64, places uint8) bool {
+		decimals := int32(places % 19)
+		return RawToDecimal(raw, decimals).Shift(decimals).StringFixed(0) == strconv.FormatUint(raw, 10)
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseAmount_Property(t *testing.T) {
+	roundTrip := func(value int64, places uint8) bool {
+		if value < 0 {
+			value = -(value + 1)
+		}
+		decimals := int32(places % 19)
+		amount, err := ParseAmount(RawToDecimal(uint64(value), decimals).String(), decimals)
+		if err != nil {
+			return false
+		}
+		raw, err := ToRawAmount(amount, decimals)
+		if err != nil {
+			return false
+		}
+		n, err := RawToUint64(raw)
+		return err == nil && n == uint64(value)
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRawToUint64_Overflow(t *testing.T) {
+	overflow := func(raw uint64) bool {
+		n, err := RawToUint64(RawToDecimal(raw, 0))
+		if raw > MaxRawAmount {
+			return AmountErrorReason(err) == ErrAmountOverflow
+		}
+		return err == nil && n == raw
+	}
+	if err := quick.Check(overflow, nil); err != nil {
+		t.Error(err)
+	}
+	for _, raw := range []uint64{0, 1, MaxRawAmount, MaxRawAmount + 1, math.MaxUint64} {
+		if !overflow(raw) {
+			t.Errorf("RawToUint64(%d) unexpected result", raw)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value    string
+		decimals int32
+		want     string
+		reason   error
+	}{
+		{value: "0", decimals: 8, want: "0"},
+		{value: "1.23", decimals: 2, want: "1.23"},
+		{value: "1.230", decimals: 2, want: "1.23"},
+		{value: "0.00000001", decimals: 8, want: "0.00000001"},
+		{value: "92233720368.54775807", decimals: 8, want: "92233720368.54775807"},
+		{value: "1000000000000000000000000000000", decimals: 18, want: "1000000000000000000000000000000"},
+		{value: "", decimals: 8, reason: ErrAmountInvalid},
+		{value: "abc", decimals: 8, reason: ErrAmountInvalid},
+		{value: "-1", decimals: 8, reason: ErrAmountNegative},
+		{value: "1.234", decimals: 2, reason: ErrAmountPrecision},
+		{value: "0.000000001", decimals: 8, reason: ErrAmountPrecision},
+	}
+	for _, test := range tests {
+		got, err := ParseAmount(test.value, test.decimals)
+		if reason := AmountErrorReason(err); reason != test.reason {
+			t.Errorf("ParseAmount(%q) error = %v, want %v", test.value, err, test.reason)
+			continue
+		}
+		if test.reason == nil && got.String() != test.want {
+			t.Errorf("ParseAmount(%q) = %s, want %s", test.value, got.String(), test.want)
+		}
+	}
+}
+
+func TestParseRawAmount(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   string
+		reason error
+	}{
+		{value: "0", want: "0"},
+		{value: "9223372036854775807", want: "9223372036854775807"},
+		{value: "18446744073709551616", want: "18446744073709551616"},
+		{value: "100000000000000000000000000000000000000", want: "100000000000000000000000000000000000000"},
+		{value: "1.0", want: "1"},
+		{value: "0.5", reason: ErrAmountPrecision},
+		{value: "-5", reason: ErrAmountNegative},
+		{value: "1e", reason: ErrAmountInvalid},
+	}
+	for _, test := range tests {
+		got, err := ParseRawAmount(test.value)
+		if reason := AmountErrorReason(err); reason != test.reason {
+			t.Errorf("ParseRawAmount(%q) error = %v, want %v", test.value, err, test.reason)
+			continue
+		}
+		if test.reason != nil {
+			continue
+		}
+		if s, err := FormatRawAmount(got); err != nil || s != test.want {
+			t.Errorf("FormatRawAmount(%q) = %s, %v, want %s", test.value, s, err, test.want)
+		}
+	}
+
+	if _, err := FormatRawAmount(decimal.New(15, -1)); AmountErrorReason(err) != ErrAmountPrecision {
+		t.Errorf("FormatRawAmount(1.5) error = %v, want precision error", err)
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		raw    decimal.Decimal
+		want   string
+		reason error
+	}{
+		{raw: decimal.New(0, 0), want: "0.00000000"},
+		{raw: decimal.New(10000000, 0), want: "0.10000000"},
+		{raw: decimal.New(123456789, 0), want: "1.23456789"},
+		{raw: decimal.New(15, -1), reason: ErrAmountPrecision},
+		{raw: decimal.New(-1, 0), reason: ErrAmountNegative},
+	}
+	for _, test := range tests {
+		got, err := FormatAmount(test.raw, 8)
+		if reason := AmountErrorReason(err); reason != test.reason {
+			t.Errorf("FormatAmount(%s) error = %v, want %v", test.raw, err, test.reason)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("FormatAmount(%s) = %s, want %s", test.raw, got, test.want)
+		}
+	}
+}
@@ -4,12 +4,21 @@ import (
 	"time"
 )
 
+const (
+	// MainnetEpoch begin time of mainnet, in unix seconds
+	MainnetEpoch = 1520193600
+)
+
 // GetEpochTime return the time span in seconds
 func GetEpochTime() int64 {
 	//d := beginEpochTime()
-	time := time.Now()
 	//return time.Unix() - d.Unix()
-	return time.Unix() - 1520193600
+	return GetEpochTimeSince(MainnetEpoch)
+}
+
+// GetEpochTimeSince return the time span in seconds since the chain epoch
+func GetEpochTimeSince(epoch int64) int64 {
+	return time.Now().Unix() - epoch
 }
 
 func beginEpochTime() time.Time {